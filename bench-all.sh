@@ -29,6 +29,16 @@ conns=16
 # Value size range, randomly selected.
 sizerange=1-1024
 
+# Latency spectrum. Setting to yes asks memtier for the latency at many
+# percentiles, instead of the usual five, for './graph --bench=spectrum'.
+# Without it, the spectrum graphs use the five stored percentiles.
+spectrum=no
+
+# Latency by value size. Setting to yes splits the load of every run between
+# memtier processes for each power of two range of sizes in the sizerange, and
+# records the latency of each range, for './graph --bench=sizes'.
+sizebuckets=no
+
 # Number of runs per benchmark.
 runs=31
 
@@ -48,8 +58,12 @@ btaskset="16-31"
 # Final directory for storing all results
 resultsdir="results"
 
+# Sweep label recorded in every run, along with the hostname. Useful when
+# combining results from several machines.
+sweep=""
+
 # Bench graphs
-benches="throughput latency cpucycles" 
+benches="throughput latency cpucycles spectrum bandwidth"
 
 # Latency percentiles
 percentiles="50 90 99 999 9999 min max avg"
@@ -76,26 +90,43 @@ bench() {
     echo "=== BENCH PROG($prog) THREADS($threads) PIPELINE($pipeline) PERF($perf) RUN($run) ===" 
     json="$(runfile $prog $threads $pipeline $perf $run)"
     if [[ ! -f "$json" ]]; then
+        extra=""
+        if [[ "$spectrum" == "yes" ]]; then
+            extra+=" --spectrum"
+        fi
+        if [[ "$sizebuckets" == "yes" ]]; then
+            extra+=" --sizebuckets"
+        fi
         ./bench $prog --threads=$threads --pipeline=$pipeline --perf=$perf \
             --ops=$nops --bthreads="$bthreads" --taskset="$ctaskset" \
-            --btaskset="$btaskset" --sizerange="$sizerange" --conns="$conns"
+            --btaskset="$btaskset" --sizerange="$sizerange" --conns="$conns" \
+            --sweep="$sweep" $extra
         chmod 666 bench.json
         mv bench.json $json
     fi
 }
 
-# choose the best, worst, and average results
+# choose the best, worst, and average results for every configuration that
+# has runs in the results directory.
 choose() {
-    prog=$1; threads=$2; pipeline=$3; perf=$4
-    ./choose --prog=$prog --threads=$threads --pipeline=$pipeline \
-        --perf=$perf --runs=$runs --path="$resultsdir"
+    ./choose --path="$resultsdir" --jobs=$(nproc)
 }
 
-graph() {
-    bench=$1; pipeline=$2; percentile=$3; op=$4; scale=$5; scase=$6; 
-    echo "=== GRAPH BENCH($bench) PIPELINE($pipeline) PERCENTILE($percentile) PERF($op) SCALE($scale) ==="
-    ./graph --bench=$bench --pipeline=$pipeline --percentile=$percentile \
-        --which=$op --scale=$scale --dir=results --scase=$scase
+# list joins the words with commas, such as "1,10,25,50" for "1 10 25 50".
+list() {
+    echo $* | tr ' ' ','
+}
+
+# Draw every graph in a single process. Existing graphs are skipped. The
+# second spec is the special case that removes garnet at 1 thread from the
+# latency.
+graphs() {
+    echo "=== GRAPHS ==="
+    spec="bench=$(list $benches) pipeline=$(list $pipelines)"
+    spec+=" percentile=$(list $percentiles) which=$(list $ops)"
+    spec+=" scale=$(list $scales)"
+    ./graph --dir=results --jobs=$(nproc) "$spec" \
+        "bench=latency pipeline=1 percentile=99 which=get,set scale=linear scase=1"
 }
 
 ################################################################################
@@ -117,33 +148,14 @@ for prog in ${progs}; do
                 for run in $(seq 1 $runs); do
                     bench $prog $threads $pipeline $perf $run
                 done
-                choose $prog $threads $pipeline $perf
             done
         done
     done
 done
+choose
 ./combine --path="$resultsdir"
 fi
 echo === SAVED OUTPUT ===
-for scale in ${scales}; do
-    for bench in ${benches}; do
-        for pipeline in ${pipelines}; do
-            if [[ "$bench" == "latency" ]]; then
-                for percentile in ${percentiles}; do
-                    for op in ${ops}; do
-                        graph $bench $pipeline $percentile $op $scale
-                    done
-                done
-            elif [[ "$bench" == "throughput" ]]; then
-                for op in ${ops}; do
-                    graph $bench $pipeline "" $op $scale
-                done
-            else
-                graph $bench $pipeline "" "" $scale
-            fi
-        done
-    done
-done
-echo "=== SPECIAL CASE(remove garnet for latency 1 thread) ==="
-graph latency 1 99 set linear 1
-graph latency 1 99 get linear 1
+graphs
+echo "=== REPORT ==="
+./report --dir=results
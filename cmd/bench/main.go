@@ -3,22 +3,27 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/tidwall/cache-benchmarks/results"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/jsonc"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -33,6 +38,10 @@ var (
 	sizerange string = "1-1024"         // bench: data size
 	proto     string                    // bench: protocol
 	tcp       bool
+	host      string // label of the machine running the bench
+	sweep     string // label of the sweep that this run belongs to
+	spectrum  bool   // record the latency at many percentiles
+	sizebkts  bool   // split the load by value size, see sizeloads
 
 	perf   string = "no"              // yes or no
 	isroot bool   = os.Geteuid() == 0 //
@@ -46,6 +55,13 @@ var (
 	net4      bool
 )
 
+// percentiles are the latency percentiles that memtier reports, and the
+// spectrumPercentiles are those used with --spectrum. Both include the
+// percentiles of the latency fields.
+const percentiles string = "50,90,99,99.9,99.99"
+const spectrumPercentiles string = "1,5,10,25,50,75,90,95,97.5,99,99.5," +
+	"99.75,99.9,99.95,99.975,99.99,99.995,99.999,99.9995,99.9999"
+
 const tcpport string = "19283"
 const unixsocket string = "/tmp/cachebench.sock"
 
@@ -54,6 +70,9 @@ var cache string
 var arch string // for dragonfly binary
 var vers string
 
+var memtier string
+var loads []load // the memtier processes of each phase
+
 func killprocs() {
 	// Kill processes
 	exec.Command("pkill", "valkey").Run()
@@ -80,6 +99,11 @@ func delfiles() {
 	os.RemoveAll("/tmp/cachebench.sock")
 	os.RemoveAll("bench-set.json")
 	os.RemoveAll("bench-get.json")
+	// the files of each load, when there are several
+	names, _ := filepath.Glob("bench-[gs]et-*.json")
+	for _, name := range names {
+		os.RemoveAll(name)
+	}
 	os.RemoveAll("perf.out")
 }
 
@@ -144,102 +168,410 @@ func getvers(cache string) string {
 	), "\n")[0]))
 }
 
-func writestats() {
-	if !success {
-		println("=== ENDED EARLY ===")
-		return
+// parsebench returns the stats of the operation, "Sets" or "Gets", in the
+// json output of memtier. The spectrum has every percentile that memtier
+// reported.
+func parsebench(bjson, op string) results.Stats {
+	var stats results.Stats
+	stats.Opsec = gjson.Get(bjson, `ALL STATS.`+op+`.Ops/sec`).Float()
+	stats.Mbsec = gjson.Get(bjson, `ALL STATS.`+op+`.KB/sec`).Float() / 1024
+	stats.Latency.Avg = gjson.Get(bjson, `ALL STATS.`+op+`.Average Latency`).Float()
+	stats.Latency.Min = gjson.Get(bjson, `ALL STATS.`+op+`.Min Latency`).Float()
+	stats.Latency.Max = gjson.Get(bjson, `ALL STATS.`+op+`.Max Latency`).Float()
+	stats.Latency.P50 = gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p50\.00`).Float()
+	stats.Latency.P90 = gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p90\.00`).Float()
+	stats.Latency.P99 = gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p99\.00`).Float()
+	stats.Latency.P999 = gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p99\.90`).Float()
+	stats.Latency.P9999 = gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p99\.99`).Float()
+	// Keys are the percentiles, such as "p99.90".
+	pcts := gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies`)
+	pcts.ForEach(func(key, value gjson.Result) bool {
+		s := strings.TrimPrefix(key.String(), "p")
+		p, err := strconv.ParseFloat(s, 64)
+		if err == nil {
+			pct := results.Percentile{P: p, Latency: value.Float()}
+			stats.Spectrum = append(stats.Spectrum, pct)
+		}
+		return true
+	})
+	sort.Slice(stats.Spectrum, func(i, j int) bool {
+		return stats.Spectrum[i].P < stats.Spectrum[j].P
+	})
+	return stats
+}
+
+// sizeranges returns the power of two ranges of value sizes that cover the
+// size range, such as "1-1", "2-3", "4-7" ... "1024-1024" for "1-1024".
+func sizeranges(sizerange string) []string {
+	lo, err1 := strconv.Atoi(left(sizerange, "-"))
+	hi, err2 := strconv.Atoi(right(sizerange, "-"))
+	if err1 != nil || err2 != nil || lo < 1 || hi < lo {
+		fmt.Fprintf(os.Stderr, "invalid sizerange: %s\n", sizerange)
+		os.Exit(1)
 	}
-	println("=== WRITE FINAL OUTPUT ===")
-	parsebench := func(bjson, op string) string {
-		opsSec := gjson.Get(bjson, `ALL STATS.`+op+`.Ops/sec`).Float()
-		avgLatency := gjson.Get(bjson, `ALL STATS.`+op+`.Average Latency`).Float()
-		minLatency := gjson.Get(bjson, `ALL STATS.`+op+`.Min Latency`).Float()
-		maxLatency := gjson.Get(bjson, `ALL STATS.`+op+`.Max Latency`).Float()
-		kbSec := gjson.Get(bjson, `ALL STATS.`+op+`.KB/sec`).Float()
-		p5000 := gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p50\.00`).Float()
-		p9000 := gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p90\.00`).Float()
-		p9900 := gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p99\.00`).Float()
-		p9990 := gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p99\.90`).Float()
-		p9999 := gjson.Get(bjson, `ALL STATS.`+op+`.Percentile Latencies.p99\.99`).Float()
-
-		var json string
-		json, _ = sjson.SetRaw(json, "opsec", fmt.Sprintf("%.3f", opsSec))
-		json, _ = sjson.SetRaw(json, "mbsec", fmt.Sprintf("%.3f", kbSec/1024))
-		json, _ = sjson.SetRaw(json, "latency.min", fmt.Sprintf("%.3f", minLatency))
-		json, _ = sjson.SetRaw(json, "latency.max", fmt.Sprintf("%.3f", maxLatency))
-		json, _ = sjson.SetRaw(json, "latency.avg", fmt.Sprintf("%.3f", avgLatency))
-		json, _ = sjson.SetRaw(json, "latency.p50_00", fmt.Sprintf("%.3f", p5000))
-		json, _ = sjson.SetRaw(json, "latency.p90_00", fmt.Sprintf("%.3f", p9000))
-		json, _ = sjson.SetRaw(json, "latency.p99_00", fmt.Sprintf("%.3f", p9900))
-		json, _ = sjson.SetRaw(json, "latency.p99_90", fmt.Sprintf("%.3f", p9990))
-		json, _ = sjson.SetRaw(json, "latency.p99_99", fmt.Sprintf("%.3f", p9999))
-		return json
-	}
-	setjson := parsebench(string(must(os.ReadFile("bench-set.json"))), "Sets")
-	getjson := parsebench(string(must(os.ReadFile("bench-get.json"))), "Gets")
-	var paramsjson string
-	paramsjson, _ = sjson.Set(paramsjson, "cache", cache)
-	paramsjson, _ = sjson.Set(paramsjson, "version", vers)
-	paramsjson, _ = sjson.Set(paramsjson, "threads", threads)
-	paramsjson, _ = sjson.Set(paramsjson, "bench_threads", bthreads)
-	paramsjson, _ = sjson.Set(paramsjson, "connections", bthreads*conns)
-	paramsjson, _ = sjson.Set(paramsjson, "operations", bthreads*conns*ops)
-	paramsjson, _ = sjson.Set(paramsjson, "sizerange", sizerange)
-	paramsjson, _ = sjson.Set(paramsjson, "pipeline", pipeline)
-
-	findkey := func(perf, key string) string {
-		return strings.TrimSpace(right(left(perf, key), "\n"))
-	}
-	finddesc := func(perf, key string) string {
-		return strings.TrimSpace(right(left(perf, key), "#"))
-	}
-
-	var perfjson = "{}"
-	b, err := os.ReadFile("perf.out")
-	if err == nil {
-		perf := string(b)
-		utilized := finddesc(perf, "CPUs utilized")
-		if utilized != "" {
-			perfjson, _ = sjson.Set(perfjson, "cpu_utilized", utilized)
+	var ranges []string
+	for b := 1; b <= hi; b *= 2 {
+		if b*2-1 >= lo {
+			ranges = append(ranges,
+				fmt.Sprintf("%d-%d", max(b, lo), min(b*2-1, hi)))
 		}
-		cycles := findkey(perf, "cycles")
-		if cycles != "" {
-			perfjson, _ = sjson.Set(perfjson, "cycles", cycles)
+	}
+	return ranges
+}
+
+// load is the share of the benchmark that a single memtier process makes.
+// Each process of a phase has its own keys.
+type load struct {
+	sizerange string // range of value sizes
+	prefix    string // key prefix
+	threads   int    // memtier threads
+	conns     int    // connections per thread
+}
+
+// sizeloads splits the connections of the benchmark between the power of two
+// ranges of value sizes, with a memtier process, or two, for each range. All
+// of the processes run at the same time, so together they are the mixed run,
+// and the stats of each range are a breakdown of it. Each range has a share
+// of the connections by the number of sizes in it, so that the sizes are as
+// mixed as they are with a single process, except that every range has at
+// least one connection. The memtier threads are split in the same way.
+func sizeloads(sizerange string, threads, conns int) []load {
+	ranges := sizeranges(sizerange)
+	total := threads * conns
+	if total < len(ranges) {
+		fmt.Fprintf(os.Stderr, "%d connections are too few for the %d "+
+			"ranges of value sizes\n", total, len(ranges))
+		os.Exit(1)
+	}
+	var widths []int
+	for _, sr := range ranges {
+		lo, _ := strconv.Atoi(left(sr, "-"))
+		hi, _ := strconv.Atoi(right(sr, "-"))
+		widths = append(widths, hi-lo+1)
+	}
+	var ls []load
+	for i, c := range apportion(total, widths) {
+		// Each thread of a process has the same number of connections,
+		// so the remainder is a second process with a single thread.
+		t := min(max(1, (threads*c+total/2)/total), c)
+		per := (c + t - 1) / t
+		ls = append(ls, load{ranges[i], "", c / per, per})
+		if c%per != 0 {
+			ls = append(ls, load{ranges[i], "", 1, c % per})
 		}
-		secsuser := findkey(perf, "seconds user")
-		if secsuser != "" {
-			perfjson, _ = sjson.Set(perfjson, "secsuser", secsuser)
+	}
+	for i := range ls {
+		ls[i].prefix = fmt.Sprintf("%d:", i)
+	}
+	return ls
+}
+
+// apportion splits the total between the weights, with the largest
+// remainder method, where each gets at least one.
+func apportion(total int, weights []int) []int {
+	var sum int
+	for _, w := range weights {
+		sum += w
+	}
+	n := make([]int, len(weights))
+	var given int
+	for i, w := range weights {
+		n[i] = max(1, total*w/sum)
+		given += n[i]
+	}
+	// over is how far above its share each is, scaled by the sum.
+	over := func(i int) int { return n[i]*sum - total*weights[i] }
+	// The shortfall goes to those furthest below their share, and the
+	// excess from the minimum of one is taken from those furthest above,
+	// or the largest of those that are even.
+	for ; given < total; given++ {
+		best := 0
+		for i := range n {
+			if over(i) < over(best) {
+				best = i
+			}
 		}
-		secssys := findkey(perf, "seconds sys")
-		if secssys != "" {
-			perfjson, _ = sjson.Set(perfjson, "secssys", secssys)
+		n[best]++
+	}
+	for ; given > total; given-- {
+		best := -1
+		for i := range n {
+			if n[i] > 1 && (best == -1 || over(i) > over(best) ||
+				(over(i) == over(best) && n[i] > n[best])) {
+				best = i
+			}
 		}
-		instructions := findkey(perf, "instructions")
-		if instructions != "" {
-			perfjson, _ = sjson.Set(perfjson, "instructions", instructions)
+		n[best]--
+	}
+	return n
+}
+
+// memtierargs returns the arguments of the memtier process of the load, with
+// the ratio of SETs to GETs, such as "1:0", that writes its stats to the json
+// file.
+func memtierargs(ratio, jsonfile string, l load) []string {
+	pcts := percentiles
+	if spectrum || len(loads) > 1 {
+		// The latency of the loads together is found from their
+		// percentiles.
+		pcts = spectrumPercentiles
+	}
+	args := []string{
+		memtier,
+		"-c", fmt.Sprint(l.conns),
+		"-t", fmt.Sprint(l.threads),
+		"-n", fmt.Sprint(ops),
+		"--distinct-client-seed",
+		"--hide-histogram",
+		"--key-prefix", l.prefix,
+		"--ratio", ratio,
+		"--data-size-range", l.sizerange,
+		"--pipeline", fmt.Sprint(pipeline),
+		"--json-out-file", jsonfile,
+		"--print-percentiles", pcts,
+		"--key-pattern=P:P",
+	}
+	if tcp {
+		args = append(args, "-p", tcpport)
+	} else {
+		args = append(args, "-S", unixsocket)
+	}
+	if btaskset != "" {
+		args = append([]string{"taskset", "-c", btaskset}, args...)
+	}
+	if proto != "" {
+		args = append(args, "--protocol", proto)
+	}
+	return args
+}
+
+// outfile returns the json file of the stats of a load in a phase, such as
+// "bench-set-3.json", or "bench-set.json" when there's a single load.
+func outfile(phase string, i int) string {
+	if len(loads) == 1 {
+		return phase + ".json"
+	}
+	return fmt.Sprintf("%s-%d.json", phase, i)
+}
+
+// runphase runs the memtier processes of the loads at the same time, and
+// waits for them to finish. The output of each is written after they're
+// done, other than the progress of the largest.
+func runphase(name, ratio, phase string) {
+	println("=== START MEMTIER " + name + " ===")
+	if len(loads) == 1 {
+		args := memtierargs(ratio, outfile(phase, 0), loads[0])
+		fmt.Printf("%s\n", args)
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stderr = os.Stderr
+		cmd.Stdout = os.Stdout
+		must(0, cmd.Run())
+		return
+	}
+	largest := 0
+	for i, l := range loads {
+		big := loads[largest]
+		if l.threads*l.conns > big.threads*big.conns {
+			largest = i
 		}
-		branches := findkey(perf, "branches")
-		if branches != "" {
-			perfjson, _ = sjson.Set(perfjson, "branches", branches)
+	}
+	cmds := make([]*exec.Cmd, len(loads))
+	outs := make([]bytes.Buffer, len(loads))
+	for i, l := range loads {
+		args := memtierargs(ratio, outfile(phase, i), l)
+		fmt.Printf("%s\n", args)
+		cmds[i] = exec.Command(args[0], args[1:]...)
+		cmds[i].Stdout = &outs[i]
+		cmds[i].Stderr = &outs[i]
+		if i == largest {
+			cmds[i].Stderr = os.Stderr
+		}
+		must(0, cmds[i].Start())
+	}
+	var errs []error
+	for i, cmd := range cmds {
+		err := cmd.Wait()
+		fmt.Printf("=== MEMTIER %s(%s bytes) ===\n", name,
+			loads[i].sizerange)
+		os.Stdout.Write(outs[i].Bytes())
+		errs = append(errs, err)
+	}
+	must(0, errors.Join(errs...))
+}
+
+// readphase returns the stats of the operation, "Sets" or "Gets", of the
+// phase. The stats of several loads are merged, and have the stats of each
+// range of value sizes.
+func readphase(phase, op string) results.Stats {
+	var parts []results.Stats
+	var weights []float64
+	for i, l := range loads {
+		data := must(os.ReadFile(outfile(phase, i)))
+		parts = append(parts, parsebench(string(data), op))
+		weights = append(weights, float64(l.threads*l.conns))
+	}
+	if len(loads) == 1 {
+		stats := parts[0]
+		if !spectrum {
+			stats.Spectrum = nil
 		}
-		misses := findkey(perf, "branch-misses")
-		if misses != "" {
-			perfjson, _ = sjson.Set(perfjson, "branch_misses", misses)
+		return stats
+	}
+	stats := mergestats(parts, weights)
+	for i := 0; i < len(loads); {
+		// The processes of a range are together.
+		j := i + 1
+		for j < len(loads) && loads[j].sizerange == loads[i].sizerange {
+			j++
+		}
+		z := mergestats(parts[i:j], weights[i:j])
+		stats.Sizes = append(stats.Sizes, results.SizeStats{
+			Sizerange: loads[i].sizerange, Opsec: z.Opsec,
+			Mbsec: z.Mbsec, Latency: z.Latency,
+		})
+		i = j
+	}
+	return stats
+}
+
+// mergestats returns the stats of processes that ran at the same time, where
+// each has a weight for its number of requests. The rates add up, and the
+// percentiles are those of all of their requests together.
+func mergestats(parts []results.Stats, weights []float64) results.Stats {
+	var stats results.Stats
+	var total float64
+	stats.Latency.Min = math.Inf(1)
+	stats.Latency.Max = math.Inf(-1)
+	for i, p := range parts {
+		stats.Opsec += p.Opsec
+		stats.Mbsec += p.Mbsec
+		stats.Latency.Avg += p.Latency.Avg * weights[i]
+		stats.Latency.Min = min(stats.Latency.Min, p.Latency.Min)
+		stats.Latency.Max = max(stats.Latency.Max, p.Latency.Max)
+		total += weights[i]
+	}
+	stats.Latency.Avg /= total
+	q := func(p float64) float64 {
+		return quantile(parts, weights, p)
+	}
+	stats.Latency.P50 = q(50)
+	stats.Latency.P90 = q(90)
+	stats.Latency.P99 = q(99)
+	stats.Latency.P999 = q(99.9)
+	stats.Latency.P9999 = q(99.99)
+	if spectrum {
+		for _, s := range strings.Split(spectrumPercentiles, ",") {
+			p := must(strconv.ParseFloat(s, 64))
+			stats.Spectrum = append(stats.Spectrum,
+				results.Percentile{P: p, Latency: q(p)})
+		}
+	}
+	return stats
+}
+
+// quantile returns the latency at the percentile of the requests of all the
+// parts, weighted by the number of their requests, from the distribution of
+// each part.
+func quantile(parts []results.Stats, weights []float64, p float64,
+) float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	var total float64
+	for i, part := range parts {
+		lo = min(lo, part.Latency.Min)
+		hi = max(hi, part.Latency.Max)
+		total += weights[i]
+	}
+	// The fraction of requests below a latency only grows with it.
+	for range 100 {
+		mid := (lo + hi) / 2
+		var below float64
+		for i, part := range parts {
+			below += weights[i] * cdf(part, mid)
+		}
+		if below/total < p/100 {
+			lo = mid
+		} else {
+			hi = mid
 		}
-		faults := findkey(perf, "page-faults")
-		if faults != "" {
-			perfjson, _ = sjson.Set(perfjson, "page_faults", faults)
+	}
+	return hi
+}
+
+// cdf returns the fraction of the requests of the stats that have a latency
+// below x. It's linear between the percentiles of the spectrum, and from the
+// min latency at the start and to the max latency at the end.
+func cdf(s results.Stats, x float64) float64 {
+	ps := append([]results.Percentile{{P: 0, Latency: s.Latency.Min}},
+		s.Spectrum...)
+	ps = append(ps, results.Percentile{P: 100, Latency: s.Latency.Max})
+	if x < ps[0].Latency {
+		return 0
+	}
+	for i := 1; i < len(ps); i++ {
+		a, b := ps[i-1], ps[i]
+		if x < b.Latency {
+			f := (x - a.Latency) / (b.Latency - a.Latency)
+			return (a.P + (b.P-a.P)*f) / 100
 		}
 	}
+	return 1
+}
 
-	json := `` +
-		`{` + "\n" +
-		`  "info": ` + gjson.Get(paramsjson, "@ugly").String() + `,` + "\n" +
-		`  "sets": ` + gjson.Get(setjson, "@ugly").String() + `,` + "\n" +
-		`  "gets": ` + gjson.Get(getjson, "@ugly").String() + ",\n" +
-		`  "perf": ` + gjson.Get(perfjson, "@ugly").String() + "\n" +
-		`}` + "\n"
+func writestats() {
+	if !success {
+		println("=== ENDED EARLY ===")
+		return
+	}
+	println("=== WRITE FINAL OUTPUT ===")
+	var run results.Run
+	run.Sets = readphase("bench-set", "Sets")
+	run.Gets = readphase("bench-get", "Gets")
+	run.Info = results.Info{
+		Cache:        cache,
+		Version:      vers,
+		Threads:      threads,
+		BenchThreads: bthreads,
+		Connections:  bthreads * conns,
+		Operations:   bthreads * conns * ops,
+		Sizerange:    sizerange,
+		Pipeline:     pipeline,
+		Host:         host,
+		Sweep:        sweep,
+	}
+
+	findkey := func(perf, key string) float64 {
+		// Values that are not numbers, such as "<not supported>", are
+		// missing.
+		s := strings.TrimSpace(right(left(perf, key), "\n"))
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return n
+	}
+	finddesc := func(perf, key string) float64 {
+		s := strings.TrimSpace(right(left(perf, key), "#"))
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return n
+	}
+
+	b, err := os.ReadFile("perf.out")
+	if err == nil {
+		perf := string(b)
+		run.Perf.CPUUtilized = finddesc(perf, "CPUs utilized")
+		run.Perf.Cycles = findkey(perf, "cycles")
+		run.Perf.SecsUser = findkey(perf, "seconds user")
+		run.Perf.SecsSys = findkey(perf, "seconds sys")
+		run.Perf.Instructions = findkey(perf, "instructions")
+		run.Perf.Branches = findkey(perf, "branches")
+		run.Perf.BranchMisses = findkey(perf, "branch-misses")
+		run.Perf.PageFaults = findkey(perf, "page-faults")
+	}
 
+	json := string(run.Encode())
 	fmt.Printf("%s\n", json)
 
 	println("Saving to bench.json\n")
@@ -290,6 +622,14 @@ func main() {
 	flag.BoolVar(&net4, "net4", false, "pogocache: net4")
 
 	flag.BoolVar(&nowarmup, "nowarmup", false, "nowarmup")
+	flag.StringVar(&host, "host", "", "host label recorded in the results "+
+		"(default is the hostname)")
+	flag.StringVar(&sweep, "sweep", "", "sweep label recorded in the results")
+	flag.BoolVar(&spectrum, "spectrum", false, "record the latency at many "+
+		"percentiles, for spectrum graphs")
+	flag.BoolVar(&sizebkts, "sizebuckets", false, "split the load "+
+		"between memtier processes for each power of two range of "+
+		"value sizes, and record the latency of each range")
 	flag.Parse()
 
 	os.Args = args
@@ -300,6 +640,13 @@ func main() {
 	if threads == 0 {
 		threads = runtime.NumCPU()
 	}
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	loads = []load{{sizerange, "", bthreads, conns}}
+	if sizebkts {
+		loads = sizeloads(sizerange, bthreads, conns)
+	}
 
 	// get arch - mainly for dragonfly
 	arch = runtime.GOARCH
@@ -309,7 +656,7 @@ func main() {
 		arch = "aarch64"
 	}
 	config = string(jsonc.ToJSONInPlace(must(os.ReadFile(configPath))))
-	memtier := gjson.Get(config, "paths.memtier").String()
+	memtier = gjson.Get(config, "paths.memtier").String()
 	vers = getvers(cache)
 	var args1 []string
 	switch cache {
@@ -435,60 +782,12 @@ func main() {
 
 	args1 = append(args1, cacheArgs...)
 
-	args2 := []string{
-		memtier,
-		"-c", fmt.Sprint(conns),
-		"-t", fmt.Sprint(bthreads),
-		"-n", fmt.Sprint(ops),
-		"--distinct-client-seed",
-		"--hide-histogram",
-		"--key-prefix", "",
-		"--ratio", "1:0",
-		"--data-size-range", sizerange,
-		"--pipeline", fmt.Sprint(pipeline),
-		"--json-out-file", "bench-set.json",
-		"--print-percentiles", "50,90,99,99.9,99.99",
-		"--key-pattern=P:P",
-	}
-	if tcp {
-		args2 = append(args2, "-p", tcpport)
-	} else {
-		args2 = append(args2, "-S", unixsocket)
-	}
-	args3 := []string{
-		memtier,
-		"-c", fmt.Sprint(conns),
-		"-t", fmt.Sprint(bthreads),
-		"-n", fmt.Sprint(ops),
-		"--distinct-client-seed",
-		"--hide-histogram",
-		"--key-prefix", "",
-		"--ratio", "0:1",
-		"--data-size-range", sizerange,
-		"--pipeline", fmt.Sprint(pipeline),
-		"--json-out-file", "bench-get.json",
-		"--print-percentiles", "50,90,99,99.9,99.99",
-		"--key-pattern=P:P",
-	}
-	if tcp {
-		args3 = append(args3, "-p", tcpport)
-	} else {
-		args3 = append(args3, "-S", unixsocket)
-	}
 	if taskset != "" {
 		args1 = append([]string{"taskset", "-c", taskset}, args1...)
 	}
 	// if perf == "yes" {
 	// 	args1 = append([]string{"perf", "stat"}, args1...)
 	// }
-	if btaskset != "" {
-		args2 = append([]string{"taskset", "-c", btaskset}, args2...)
-		args3 = append([]string{"taskset", "-c", btaskset}, args3...)
-	}
-	if proto != "" {
-		args2 = append(args2, "--protocol", proto)
-		args3 = append(args3, "--protocol", proto)
-	}
 
 	//////////////////////////////////////////////////////////////////////////
 	cleanup()
@@ -554,17 +853,11 @@ func main() {
 	}
 	time.Sleep(time.Millisecond * 100)
 
-	var cmd2 *exec.Cmd
 	if !nowarmup {
 		// The server is up and running. Perform a warmup SET benchmark.
 		// This will ensure that the hashtables are filled, giving the final
 		// SET benchmark the best opportunity for lowest latency.
-		println("=== START MEMTIER SET(warmup) ===")
-		fmt.Printf("%s\n", args2)
-		cmd2 = exec.Command(args2[0], args2[1:]...)
-		cmd2.Stderr = os.Stderr
-		cmd2.Stdout = os.Stdout
-		must(0, cmd2.Run())
+		runphase("SET(warmup)", "1:0", "bench-set")
 	}
 
 	// The server is up and running and the warmup run has finished.
@@ -611,25 +904,14 @@ func main() {
 		}()
 	}
 
-	println("=== START MEMTIER SET ===")
-	fmt.Printf("%s\n", args2)
-	cmd2 = exec.Command(args2[0], args2[1:]...)
-	cmd2.Stderr = os.Stderr
-	cmd2.Stdout = os.Stdout
-	must(0, cmd2.Run())
+	runphase("SET", "1:0", "bench-set")
+	runphase("GET", "0:1", "bench-get")
 
-	println("=== START MEMTIER GET ===")
-	fmt.Printf("%s\n", args3)
-	cmd2 = exec.Command(args3[0], args3[1:]...)
-	cmd2.Stderr = os.Stderr
-	cmd2.Stdout = os.Stdout
-	must(0, cmd2.Run())
-
-	fmt.Printf("=== BENCHMARK COMPLETE ===\n")
 	if perf == "yes" {
 		exec.Command("sudo", "kill", "-INT", fmt.Sprint(cmdP.Process.Pid)).Run()
 		perfwg.Wait()
 	}
+	fmt.Printf("=== BENCHMARK COMPLETE ===\n")
 	killprocs()
 	success = true
 	writestats()
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+// near returns true when a and b are equal to within 1e-3.
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestApportion(t *testing.T) {
+	tests := []struct {
+		total   int
+		weights []int
+		want    []int
+	}{
+		{256, []int{1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1},
+			[]int{1, 1, 1, 2, 4, 8, 16, 32, 63, 127, 1}},
+		{10, []int{1, 1, 1}, []int{4, 3, 3}},
+		{3, []int{1, 1, 100}, []int{1, 1, 1}},
+		{7, []int{5}, []int{7}},
+	}
+	for _, tt := range tests {
+		got := apportion(tt.total, tt.weights)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("apportion(%d, %v) = %v, want %v", tt.total,
+				tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestSizeloads(t *testing.T) {
+	ls := sizeloads("1-1024", 16, 16)
+	want := []struct {
+		sizerange      string
+		threads, conns int
+	}{
+		{"1-1", 1, 1}, {"2-3", 1, 1}, {"4-7", 1, 1}, {"8-15", 1, 2},
+		{"16-31", 1, 4}, {"32-63", 1, 8}, {"64-127", 1, 16},
+		{"128-255", 2, 16}, {"256-511", 3, 16}, {"256-511", 1, 15},
+		{"512-1023", 7, 16}, {"512-1023", 1, 15}, {"1024-1024", 1, 1},
+	}
+	if len(ls) != len(want) {
+		t.Fatalf("%d loads, want %d: %v", len(ls), len(want), ls)
+	}
+	var total int
+	for i, l := range ls {
+		w := want[i]
+		prefix := fmt.Sprintf("%d:", i)
+		if l.sizerange != w.sizerange || l.threads != w.threads ||
+			l.conns != w.conns || l.prefix != prefix {
+			t.Errorf("load %d is %+v, want %+v", i, l, w)
+		}
+		total += l.threads * l.conns
+	}
+	if total != 256 {
+		t.Errorf("%d connections, want 256", total)
+	}
+}
+
+func TestMergestats(t *testing.T) {
+	// Each part has latencies spread evenly between its min and max.
+	even := func(lo, hi, opsec float64) results.Stats {
+		s := results.Stats{Opsec: opsec, Mbsec: opsec / 10}
+		s.Latency.Min, s.Latency.Max = lo, hi
+		s.Latency.Avg = (lo + hi) / 2
+		for _, p := range []float64{50, 90, 99, 99.9, 99.99} {
+			s.Spectrum = append(s.Spectrum, results.Percentile{
+				P: p, Latency: lo + (hi-lo)*p/100,
+			})
+		}
+		return s
+	}
+	// The want is the avg, p50, p90, p99 and p99.9 latency, and the opsec.
+	tests := []struct {
+		name    string
+		parts   []results.Stats
+		weights []float64
+		want    []float64
+	}{
+		{"same", []results.Stats{even(1, 2, 10), even(1, 2, 30)},
+			[]float64{1, 3},
+			[]float64{1.5, 1.5, 1.9, 1.99, 1.999, 40}},
+		{"apart", []results.Stats{even(0, 1, 10), even(1, 2, 10)},
+			[]float64{1, 1}, []float64{1, 1, 1.8, 1.98, 1.998, 20}},
+		{"weighted", []results.Stats{even(0, 1, 10), even(1, 2, 10)},
+			[]float64{3, 1},
+			[]float64{0.75, 2.0 / 3, 1.6, 1.96, 1.996, 20}},
+	}
+	for _, tt := range tests {
+		s := mergestats(tt.parts, tt.weights)
+		got := []float64{s.Latency.Avg, s.Latency.P50, s.Latency.P90,
+			s.Latency.P99, s.Latency.P999, s.Opsec}
+		for i := range got {
+			if !near(got[i], tt.want[i]) {
+				t.Errorf("%s: merged to %v, want %v", tt.name,
+					got, tt.want)
+				break
+			}
+		}
+		if s.Latency.Min != tt.parts[0].Latency.Min ||
+			s.Latency.Max != tt.parts[1].Latency.Max {
+			t.Errorf("%s: latency from %v to %v", tt.name,
+				s.Latency.Min, s.Latency.Max)
+		}
+		if s.Spectrum != nil {
+			t.Errorf("%s: has a spectrum without --spectrum",
+				tt.name)
+		}
+	}
+}
+
+func TestReadphase(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(dir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { loads = nil }()
+	loads = []load{
+		{"1-1", "0:", 1, 1}, {"2-3", "1:", 2, 2}, {"2-3", "2:", 1, 4},
+	}
+	for i, lat := range []float64{1, 2, 3} {
+		data := fmt.Sprintf(`{"ALL STATS":{"Gets":{"Ops/sec":100,`+
+			`"KB/sec":1024,"Average Latency":%[1]v,`+
+			`"Min Latency":%[1]v,"Max Latency":%[1]v,`+
+			`"Percentile Latencies":{"p50.00":%[1]v,`+
+			`"p99.00":%[1]v}}}}`, lat)
+		err := os.WriteFile(outfile("bench-get", i), []byte(data), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := readphase("bench-get", "Gets")
+	if s.Opsec != 300 || s.Mbsec != 3 || !near(s.Latency.Avg, 21.0/9) ||
+		!near(s.Latency.P50, 2) || !near(s.Latency.P99, 3) {
+		t.Errorf("merged to %+v", s)
+	}
+	if len(s.Sizes) != 2 {
+		t.Fatalf("sizes are %+v, want 2", s.Sizes)
+	}
+	one, two := s.Sizes[0], s.Sizes[1]
+	if one.Sizerange != "1-1" || one.Opsec != 100 || one.Latency.P50 != 1 ||
+		two.Sizerange != "2-3" || two.Opsec != 200 ||
+		!near(two.Latency.Avg, 2.5) || two.Latency.Max != 3 {
+		t.Errorf("sizes are %+v", s.Sizes)
+	}
+}
@@ -0,0 +1,231 @@
+package chart
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Font sizes, in canvas units.
+const (
+	titleSize  = 26
+	axisSize   = 22
+	tickSize   = 16
+	minorSize  = 10
+	legendSize = 16
+)
+
+// axis is a vertical value axis.
+type axis struct {
+	log   bool
+	min   float64
+	max   float64
+	major []float64 // labeled gridlines
+	minor []float64 // light gridlines
+}
+
+// newAxis returns an axis that fits the values. Logarithmic axes span whole
+// decades with lines at every eighth of a decade. Linear axes start at zero,
+// or below it for negative values, and have 20 labeled lines, each divided
+// into quarters.
+func newAxis(values []float64, log bool) axis {
+	a := axis{log: log}
+	var lo, hi float64
+	for _, v := range values {
+		if math.IsNaN(v) || (log && v <= 0) {
+			continue
+		}
+		if lo == 0 || v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	if log {
+		if hi == 0 {
+			lo, hi = 1, 10
+		}
+		e0 := math.Floor(math.Log10(lo))
+		e1 := math.Ceil(math.Log10(hi))
+		if e1 <= e0 {
+			e1 = e0 + 1
+		}
+		a.min, a.max = math.Pow(10, e0), math.Pow(10, e1)
+		for e := e0; e <= e1; e++ {
+			a.major = append(a.major, math.Pow(10, e))
+		}
+		for e := e0; e < e1; e += 0.125 {
+			if e != math.Floor(e) {
+				a.minor = append(a.minor, math.Pow(10, e))
+			}
+		}
+		return a
+	}
+	if hi == 0 && lo >= 0 {
+		hi = 1
+	}
+	a.max = hi * 1.1
+	const n = 20
+	step := a.max / (n - 1)
+	if lo < 0 {
+		// Zero stays on a line, with enough lines below it for the lowest
+		// value.
+		step = (hi - lo) * 1.1 / (n - 1)
+		a.min = -math.Ceil(-lo*1.05/step) * step
+		a.max = a.min + step*(n-1)
+	}
+	for i := 0; i < n; i++ {
+		v := a.min + step*float64(i)
+		a.major = append(a.major, v)
+		if i < n-1 {
+			for j := 1; j < 4; j++ {
+				a.minor = append(a.minor, v+step*float64(j)/4)
+			}
+		}
+	}
+	return a
+}
+
+// pos returns the vertical position of the value on an axis that spans from
+// top to bottom.
+func (a axis) pos(v, top, bottom float64) float64 {
+	var t float64
+	if a.log {
+		v = math.Max(v, a.min)
+		t = (math.Log10(v) - math.Log10(a.min)) /
+			(math.Log10(a.max) - math.Log10(a.min))
+	} else {
+		t = (v - a.min) / (a.max - a.min)
+	}
+	return bottom - t*(bottom-top)
+}
+
+// tick returns the label of a major line. Linear labels are truncated, unless
+// the lines are closer than one apart.
+func (a axis) tick(v float64) string {
+	if a.log {
+		return Label(v)
+	}
+	if step := a.major[1] - a.major[0]; step < 1 {
+		return strconv.FormatFloat(v, 'f', int(math.Ceil(-math.Log10(step)))+1,
+			64)
+	}
+	if math.Abs(v) >= 1 {
+		v = math.Trunc(v)
+	}
+	return Label(v)
+}
+
+// Label formats a value with thousands separators, or with three significant
+// digits when it is small and not whole.
+func Label(v float64) string {
+	if v != 0 && (math.Abs(v) < 1 || (math.Abs(v) < 10 && v != math.Trunc(v))) {
+		return strconv.FormatFloat(v, 'g', 3, 64)
+	}
+	s := strconv.FormatInt(int64(math.Round(v)), 10)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// frame is the plotting area of a chart.
+type frame struct {
+	left, top, right, bottom float64
+}
+
+// newFrame returns the plotting area that leaves room for the titles, the
+// value labels and the legend.
+func newFrame(a axis, names []string) frame {
+	var labelw float64
+	for _, v := range a.major {
+		labelw = math.Max(labelw, MeasureText(a.tick(v), tickSize, false))
+	}
+	var legendw float64
+	for _, name := range names {
+		legendw = math.Max(legendw, MeasureText(name, legendSize, false))
+	}
+	if legendw > 0 {
+		legendw += legendSize + 10 + 30
+	}
+	return frame{
+		left:   20 + axisSize + 20 + labelw + 14,
+		top:    100,
+		right:  Width - 30 - legendw,
+		bottom: Height - 90,
+	}
+}
+
+// drawAxis draws the gridlines, value labels and titles.
+func drawAxis(c Canvas, f frame, a axis, title, xtitle, ytitle string) {
+	minorAlpha := 0.2
+	if a.log {
+		minorAlpha = 0.3
+	}
+	for _, v := range a.minor {
+		y := a.pos(v, f.top, f.bottom)
+		c.Line(f.left, y, f.right, y,
+			Style{Stroke: Gray.Alpha(minorAlpha), Width: 0.5})
+		if a.log {
+			c.Text(f.left-8, y, Label(v), TextStyle{
+				Size: minorSize, Color: Gray, Anchor: End,
+			})
+		}
+	}
+	for _, v := range a.major {
+		y := a.pos(v, f.top, f.bottom)
+		c.Line(f.left, y, f.right, y, Style{Stroke: Gray.Alpha(0.7), Width: 1})
+		size := float64(tickSize)
+		if a.log {
+			size++
+		}
+		c.Text(f.left-14, y, a.tick(v), TextStyle{
+			Size: size, Color: Black, Anchor: End,
+		})
+	}
+	drawTitles(c, f, title, xtitle, ytitle)
+}
+
+// drawTitles draws the chart title above the frame, and the axis titles
+// below and to the left of it.
+func drawTitles(c Canvas, f frame, title, xtitle, ytitle string) {
+	c.Text((f.left+f.right)/2, 45, title, TextStyle{
+		Size: titleSize, Color: Black, Bold: true, Anchor: Middle,
+	})
+	c.Text((f.left+f.right)/2, Height-35, xtitle, TextStyle{
+		Size: axisSize, Color: Black, Bold: true, Anchor: Middle,
+	})
+	c.Text(20+axisSize/2, (f.top+f.bottom)/2, ytitle, TextStyle{
+		Size: axisSize, Color: Black, Bold: true, Anchor: Middle,
+		Rotated: true,
+	})
+}
+
+// drawLegend draws a swatch and name for each series, vertically centered to
+// the right of the frame.
+func drawLegend(c Canvas, f frame, names []string, colors []Color,
+	hatches []string,
+) {
+	const spacing = legendSize * 2.2
+	y := (f.top+f.bottom)/2 - spacing*float64(len(names)-1)/2
+	x := f.right + 30
+	for i, name := range names {
+		c.Rect(x, y-legendSize/2, legendSize, legendSize, Style{
+			Fill: colors[i], Stroke: colors[i].Darken(0.4), Width: 1.5,
+		})
+		if i < len(hatches) {
+			drawHatch(c, x, y-legendSize/2, legendSize, legendSize, hatches[i],
+				colors[i].Darken(0.4))
+		}
+		c.Text(x+legendSize+10, y, name, TextStyle{
+			Size: legendSize, Color: Black,
+		})
+		y += spacing
+	}
+}
@@ -0,0 +1,145 @@
+package chart
+
+import (
+	"math"
+)
+
+// Series is a named set of values, one for each x-axis position. Missing
+// values are NaN. The optional Low and High are the range that each value
+// spans, such as the minimum and maximum across runs. Box plots also use the
+// quartiles and outliers. The optional Notes annotate values, and are drawn
+// even when the value is missing. The optional Hatch is a pattern drawn over
+// the bars, such as "//".
+type Series struct {
+	Name     string
+	Color    Color
+	Hatch    string
+	Values   []float64
+	Low      []float64
+	High     []float64
+	Q1       []float64
+	Q3       []float64
+	Outliers [][]float64
+	Notes    []string
+}
+
+// note returns the annotation of the value at i, or an empty string.
+func (s Series) note(i int) string {
+	if i >= len(s.Notes) {
+		return ""
+	}
+	return s.Notes[i]
+}
+
+// drawNote draws an annotation that reads upwards from just above y.
+func drawNote(c Canvas, x, y float64, text string, color Color) {
+	if text == "" {
+		return
+	}
+	c.Text(x, y-6, text, TextStyle{
+		Size: minorSize + 2, Color: color.Darken(0.5), Rotated: true,
+	})
+}
+
+// span returns the range of the value at i, if there is one.
+func (s Series) span(i int) (lo, hi float64, ok bool) {
+	if i >= len(s.Low) || i >= len(s.High) ||
+		math.IsNaN(s.Low[i]) || math.IsNaN(s.High[i]) {
+		return 0, 0, false
+	}
+	return s.Low[i], s.High[i], true
+}
+
+// seriesValues returns all values, including the ranges, of the series.
+func seriesValues(series []Series) []float64 {
+	var values []float64
+	for _, s := range series {
+		values = append(values, s.Values...)
+		values = append(values, s.Low...)
+		values = append(values, s.High...)
+		for _, outliers := range s.Outliers {
+			values = append(values, outliers...)
+		}
+	}
+	return values
+}
+
+// barWidth returns the width of each bar in a group, where unit is the width
+// that each x-axis position has. The groups fill the same amount of space for
+// any number of series.
+func barWidth(unit float64, n int) float64 {
+	return unit * 0.72 / float64(max(n, 1))
+}
+
+// drawRange draws a vertical line from lo to hi with a cap at each end.
+func drawRange(c Canvas, x, lo, hi, capw float64, color Color) {
+	s := Style{Stroke: color.Darken(0.6), Width: 1.5}
+	c.Line(x, lo, x, hi, s)
+	c.Line(x-capw/2, lo, x+capw/2, lo, s)
+	c.Line(x-capw/2, hi, x+capw/2, hi, s)
+}
+
+// Bar is a grouped bar chart. Each x-axis position has one bar per series.
+type Bar struct {
+	Title  string
+	XTitle string
+	YTitle string
+	X      []string
+	Series []Series
+	Log    bool
+}
+
+// Draw draws the bar chart onto the canvas.
+func (b *Bar) Draw(c Canvas) {
+	var names, hatches []string
+	var colors []Color
+	for _, s := range b.Series {
+		names = append(names, s.Name)
+		colors = append(colors, s.Color)
+		hatches = append(hatches, s.Hatch)
+	}
+	a := newAxis(seriesValues(b.Series), b.Log)
+	f := newFrame(a, names)
+	drawAxis(c, f, a, b.Title, b.XTitle, b.YTitle)
+
+	// Bars grow from zero, which is the bottom unless there are negative
+	// values.
+	base := f.bottom
+	if !b.Log {
+		base = a.pos(0, f.top, f.bottom)
+	}
+	unit := (f.right - f.left) / float64(len(b.X))
+	width := barWidth(unit, len(b.Series))
+	for i, x := range b.X {
+		center := f.left + unit*(float64(i)+0.5)
+		start := center - width*float64(len(b.Series))/2
+		for j, s := range b.Series {
+			if i >= len(s.Values) {
+				continue
+			}
+			v := s.Values[i]
+			x := start + width*float64(j)
+			if math.IsNaN(v) || (b.Log && v <= 0) {
+				drawNote(c, x+width/2, base, s.note(i), s.Color)
+				continue
+			}
+			y := a.pos(v, f.top, f.bottom)
+			c.Rect(x, math.Min(y, base), width, math.Abs(base-y), Style{
+				Fill: s.Color, Stroke: s.Color.Darken(0.4), Width: 1.5,
+			})
+			drawHatch(c, x, math.Min(y, base), width, math.Abs(base-y),
+				s.Hatch, s.Color.Darken(0.4))
+			top := math.Min(y, base)
+			if lo, hi, ok := s.span(i); ok && (!b.Log || lo > 0) {
+				drawRange(c, x+width/2, a.pos(lo, f.top, f.bottom),
+					a.pos(hi, f.top, f.bottom), width/2, s.Color)
+				top = math.Min(top, a.pos(hi, f.top, f.bottom))
+			}
+			drawNote(c, x+width/2, top, s.note(i), s.Color)
+		}
+		c.Text(center, f.bottom+22, x, TextStyle{
+			Size: tickSize, Color: Black, Anchor: Middle,
+		})
+	}
+	drawLegend(c, f, names, colors, hatches)
+}
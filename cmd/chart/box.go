@@ -0,0 +1,83 @@
+package chart
+
+import "math"
+
+// Box is a grouped box plot. Each x-axis position has one box per series,
+// spanning from the first to the third quartile of Q1 and Q3, with a line at
+// the median in Values. The whiskers reach to Low and High, and the outliers
+// beyond them are drawn as circles.
+type Box struct {
+	Title  string
+	XTitle string
+	YTitle string
+	X      []string
+	Series []Series
+	Log    bool
+}
+
+// Draw draws the box plot onto the canvas.
+func (b *Box) Draw(c Canvas) {
+	var names, hatches []string
+	var colors []Color
+	for _, s := range b.Series {
+		names = append(names, s.Name)
+		colors = append(colors, s.Color)
+		hatches = append(hatches, s.Hatch)
+	}
+	a := newAxis(seriesValues(b.Series), b.Log)
+	f := newFrame(a, names)
+	drawAxis(c, f, a, b.Title, b.XTitle, b.YTitle)
+
+	valid := func(v float64) bool {
+		return !math.IsNaN(v) && (!b.Log || v > 0)
+	}
+	unit := (f.right - f.left) / float64(len(b.X))
+	width := barWidth(unit, len(b.Series))
+	for i, x := range b.X {
+		center := f.left + unit*(float64(i)+0.5)
+		start := center - width*float64(len(b.Series))/2
+		for j, s := range b.Series {
+			x := start + width*float64(j)
+			mid := x + width/2
+			if i >= len(s.Values) || i >= len(s.Q1) || i >= len(s.Q3) ||
+				!valid(s.Values[i]) || !valid(s.Q1[i]) || !valid(s.Q3[i]) {
+				drawNote(c, mid, f.bottom, s.note(i), s.Color)
+				continue
+			}
+			q1 := a.pos(s.Q1[i], f.top, f.bottom)
+			q3 := a.pos(s.Q3[i], f.top, f.bottom)
+			top := q3
+			if lo, hi, ok := s.span(i); ok && valid(lo) && valid(hi) {
+				drawRange(c, mid, a.pos(lo, f.top, f.bottom),
+					a.pos(hi, f.top, f.bottom), width/2, s.Color)
+				top = math.Min(top, a.pos(hi, f.top, f.bottom))
+			}
+			// Keep boxes with no spread visible.
+			h := math.Max(q1-q3, 1)
+			c.Rect(x+1, q1-h, width-2, h, Style{
+				Fill: s.Color, Stroke: s.Color.Darken(0.4), Width: 1.5,
+			})
+			drawHatch(c, x+1, q1-h, width-2, h, s.Hatch, s.Color.Darken(0.4))
+			y := a.pos(s.Values[i], f.top, f.bottom)
+			c.Line(x+1, y, x+width-1, y, Style{
+				Stroke: s.Color.Darken(0.4), Width: 2.5,
+			})
+			if i < len(s.Outliers) {
+				for _, v := range s.Outliers[i] {
+					if valid(v) {
+						y := a.pos(v, f.top, f.bottom)
+						c.Circle(mid, y, 2, Style{
+							Stroke: s.Color.Darken(0.6), Width: 1,
+						})
+						top = math.Min(top, y-2)
+					}
+				}
+			}
+			drawNote(c, mid, top, s.note(i), s.Color)
+		}
+		c.Text(center, f.bottom+22, x, TextStyle{
+			Size: tickSize, Color: Black, Anchor: Middle,
+		})
+	}
+	drawLegend(c, f, names, colors, hatches)
+}
@@ -0,0 +1,110 @@
+// Package chart renders benchmark graphs as SVG or PNG images without any
+// external tools.
+//
+// Charts draw themselves onto a Canvas using a small set of primitives. The
+// SVG and PNG outputs are both produced from the same drawing, so they look
+// the same.
+package chart
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Width and Height are the size of every chart, in canvas units. The SVG
+// output uses these as pixels. PNG output is scaled.
+const (
+	Width  = 1200
+	Height = 700
+)
+
+// Color is an RGBA color with non-premultiplied alpha.
+type Color struct {
+	R, G, B, A uint8
+}
+
+var (
+	White = Color{255, 255, 255, 255}
+	Black = Color{0, 0, 0, 255}
+	Gray  = Color{128, 128, 128, 255}
+	None  = Color{}
+)
+
+// ParseColor parses a color in the "#rrggbb" form.
+func ParseColor(s string) (Color, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return Color{}, fmt.Errorf("invalid color '%s'", s)
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color '%s'", s)
+	}
+	return Color{uint8(n >> 16), uint8(n >> 8), uint8(n), 255}, nil
+}
+
+// Alpha returns the color with its opacity set to a, which is from 0 to 1.
+func (c Color) Alpha(a float64) Color {
+	c.A = uint8(a*255 + 0.5)
+	return c
+}
+
+// Darken returns the color with each channel multiplied by f.
+func (c Color) Darken(f float64) Color {
+	return Color{
+		uint8(float64(c.R) * f), uint8(float64(c.G) * f),
+		uint8(float64(c.B) * f), c.A,
+	}
+}
+
+func (c Color) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Style is how a shape is filled and outlined. A zero color is not drawn.
+type Style struct {
+	Fill   Color
+	Stroke Color
+	Width  float64 // stroke width
+}
+
+// Anchor is the horizontal alignment of text relative to its position.
+type Anchor int
+
+const (
+	Start Anchor = iota
+	Middle
+	End
+)
+
+// TextStyle is how text is drawn. The position of text is the vertical
+// center of the line, horizontally aligned by Anchor. Rotated text reads from
+// bottom to top.
+type TextStyle struct {
+	Size    float64
+	Color   Color
+	Bold    bool
+	Anchor  Anchor
+	Rotated bool
+}
+
+// Canvas is a surface that charts are drawn onto. Coordinates start at the
+// top-left corner.
+type Canvas interface {
+	Rect(x, y, w, h float64, s Style)
+	Line(x1, y1, x2, y2 float64, s Style)
+	Polyline(xs, ys []float64, s Style)
+	Circle(x, y, r float64, s Style)
+	Text(x, y float64, text string, s TextStyle)
+}
+
+// Chart is anything that can be drawn onto a canvas.
+type Chart interface {
+	Draw(c Canvas)
+}
+
+// MeasureText returns the width of text in canvas units.
+func MeasureText(text string, size float64, bold bool) float64 {
+	return measure(text, size, bold)
+}
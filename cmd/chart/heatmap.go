@@ -0,0 +1,183 @@
+package chart
+
+import (
+	"math"
+	"strings"
+)
+
+// scale are the colors of the heatmap scale, from low to high.
+var scale = []Color{
+	{0x44, 0x01, 0x54, 255}, {0x3b, 0x52, 0x8b, 255}, {0x21, 0x91, 0x8c, 255},
+	{0x5e, 0xc9, 0x62, 255}, {0xfd, 0xe7, 0x25, 255},
+}
+
+// scaleColor returns the color at t, from 0 to 1, along the scale.
+func scaleColor(t float64) Color {
+	t = math.Max(0, math.Min(1, t)) * float64(len(scale)-1)
+	i := int(math.Min(t, float64(len(scale)-2)))
+	a, b := scale[i], scale[i+1]
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*(t-float64(i))))
+	}
+	return Color{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// textColor returns black or white, whichever is easier to read on the
+// background.
+func textColor(bg Color) Color {
+	if 0.299*float64(bg.R)+0.587*float64(bg.G)+0.114*float64(bg.B) > 140 {
+		return Black
+	}
+	return White
+}
+
+// Heatmap is a grid of cells, with a column for each X and a row for each Y,
+// starting from the bottom. Cells are colored by their value along a scale,
+// or by Colors when it's set, in which case the series of the Legend name
+// the colors. Each cell shows its Text, or its value when there's no text.
+// Missing values are NaN.
+type Heatmap struct {
+	Title    string
+	Subtitle string
+	XTitle   string
+	YTitle   string
+	X        []string
+	Y        []string
+	Values   [][]float64 // [y][x]
+	Text     [][]string  // optional, lines are separated by "\n"
+	Colors   [][]Color   // optional
+	Legend   []Series
+	Log      bool
+}
+
+// Draw draws the heatmap onto the canvas.
+func (h *Heatmap) Draw(c Canvas) {
+	var labelw float64
+	for _, y := range h.Y {
+		labelw = math.Max(labelw, MeasureText(y, tickSize, false))
+	}
+	var names []string
+	var colors []Color
+	for _, s := range h.Legend {
+		names = append(names, s.Name)
+		colors = append(colors, s.Color)
+	}
+	var legendw float64
+	if h.Colors == nil {
+		legendw = 110
+	} else {
+		for _, name := range names {
+			legendw = math.Max(legendw, MeasureText(name, legendSize, false))
+		}
+		legendw += legendSize + 10 + 30
+	}
+	f := frame{
+		left:   20 + axisSize + 20 + labelw + 14,
+		top:    110,
+		right:  Width - 30 - legendw,
+		bottom: Height - 90,
+	}
+	drawTitles(c, f, h.Title, h.XTitle, h.YTitle)
+	c.Text((f.left+f.right)/2, 80, h.Subtitle, TextStyle{
+		Size: legendSize + 2, Color: Black, Anchor: Middle,
+	})
+
+	// The value range, for the scale.
+	lo, hi := math.Inf(1), math.Inf(-1)
+	tr := func(v float64) float64 {
+		if h.Log {
+			return math.Log10(v)
+		}
+		return v
+	}
+	valid := func(v float64) bool {
+		return !math.IsNaN(v) && (!h.Log || v > 0)
+	}
+	for _, row := range h.Values {
+		for _, v := range row {
+			if valid(v) {
+				lo, hi = math.Min(lo, tr(v)), math.Max(hi, tr(v))
+			}
+		}
+	}
+	at := func(v float64) float64 {
+		if hi <= lo {
+			return 1
+		}
+		return (tr(v) - lo) / (hi - lo)
+	}
+
+	cw := (f.right - f.left) / float64(len(h.X))
+	ch := (f.bottom - f.top) / float64(len(h.Y))
+	for i, x := range h.X {
+		c.Text(f.left+cw*(float64(i)+0.5), f.bottom+22, x, TextStyle{
+			Size: tickSize, Color: Black, Anchor: Middle,
+		})
+	}
+	for j, y := range h.Y {
+		cy := f.bottom - ch*(float64(j)+0.5)
+		c.Text(f.left-14, cy, y, TextStyle{
+			Size: tickSize, Color: Black, Anchor: End,
+		})
+		for i := range h.X {
+			x := f.left + cw*float64(i)
+			v := math.NaN()
+			if j < len(h.Values) && i < len(h.Values[j]) {
+				v = h.Values[j][i]
+			}
+			bg := Gray.Alpha(0.15)
+			if h.Colors != nil {
+				if j < len(h.Colors) && i < len(h.Colors[j]) {
+					bg = h.Colors[j][i]
+				}
+			} else if valid(v) {
+				bg = scaleColor(at(v))
+			}
+			c.Rect(x, cy-ch/2, cw, ch, Style{Fill: bg, Stroke: White, Width: 2})
+			text := ""
+			if j < len(h.Text) && i < len(h.Text[j]) {
+				text = h.Text[j][i]
+			} else if valid(v) {
+				text = Label(v)
+			}
+			lines := strings.Split(text, "\n")
+			for k, line := range lines {
+				ly := cy + (float64(k)-float64(len(lines)-1)/2)*(tickSize-1)
+				c.Text(x+cw/2, ly, line, TextStyle{
+					Size: tickSize - 3, Color: textColor(bg), Anchor: Middle,
+				})
+			}
+		}
+	}
+
+	if h.Colors != nil {
+		drawLegend(c, f, names, colors, nil)
+		return
+	}
+	// Color scale, with the lowest value at the bottom.
+	if hi < lo {
+		return
+	}
+	const n = 50
+	sx, sw := f.right+30, 20.0
+	for k := 0; k < n; k++ {
+		y0 := f.bottom - (f.bottom-f.top)*float64(k+1)/n
+		c.Rect(sx, y0, sw, (f.bottom-f.top)/n+0.5, Style{
+			Fill: scaleColor((float64(k) + 0.5) / n),
+		})
+	}
+	inv := func(t float64) float64 {
+		v := lo + (hi-lo)*t
+		if h.Log {
+			return math.Pow(10, v)
+		}
+		return v
+	}
+	for _, t := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		y := f.bottom - (f.bottom-f.top)*t
+		c.Line(sx+sw, y, sx+sw+4, y, Style{Stroke: Black, Width: 1})
+		c.Text(sx+sw+8, y, Label(inv(t)), TextStyle{
+			Size: minorSize + 2, Color: Black,
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package chart
+
+import (
+	"math"
+	"strconv"
+)
+
+// Line is a line chart with a curve for each series. When every x-axis label
+// is a number, the points are placed proportionally to their value, otherwise
+// they are evenly spaced. Series that have a Low and High get a marker
+// showing that range at each point.
+type Line struct {
+	Title  string
+	XTitle string
+	YTitle string
+	X      []string
+	Series []Series
+	Log    bool
+}
+
+// xpos returns the horizontal position of each x-axis label.
+func xpos(labels []string, left, right float64) []float64 {
+	pad := (right - left) * 0.04
+	left, right = left+pad, right-pad
+	xs := make([]float64, len(labels))
+	nums := make([]float64, len(labels))
+	numeric := len(labels) > 1
+	for i, label := range labels {
+		n, err := strconv.ParseFloat(label, 64)
+		if err != nil {
+			numeric = false
+			break
+		}
+		nums[i] = n
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, n := range nums {
+		lo, hi = math.Min(lo, n), math.Max(hi, n)
+	}
+	if !numeric || hi == lo {
+		for i := range labels {
+			if len(labels) == 1 {
+				xs[i] = (left + right) / 2
+			} else {
+				xs[i] = left + (right-left)*float64(i)/float64(len(labels)-1)
+			}
+		}
+		return xs
+	}
+	for i, n := range nums {
+		xs[i] = left + (right-left)*(n-lo)/(hi-lo)
+	}
+	return xs
+}
+
+// Draw draws the line chart onto the canvas.
+func (l *Line) Draw(c Canvas) {
+	var names []string
+	var colors []Color
+	for _, s := range l.Series {
+		names = append(names, s.Name)
+		colors = append(colors, s.Color)
+	}
+	a := newAxis(seriesValues(l.Series), l.Log)
+	f := newFrame(a, names)
+	drawAxis(c, f, a, l.Title, l.XTitle, l.YTitle)
+
+	xs := xpos(l.X, f.left, f.right)
+	for i, x := range l.X {
+		c.Text(xs[i], f.bottom+22, x, TextStyle{
+			Size: tickSize, Color: Black, Anchor: Middle,
+		})
+	}
+	valid := func(v float64) bool {
+		return !math.IsNaN(v) && (!l.Log || v > 0)
+	}
+	for _, s := range l.Series {
+		for i := range l.X {
+			lo, hi, ok := s.span(i)
+			if ok && valid(lo) && valid(hi) {
+				drawRange(c, xs[i], a.pos(lo, f.top, f.bottom),
+					a.pos(hi, f.top, f.bottom), 10, s.Color.Alpha(0.8))
+			}
+		}
+	}
+	for _, s := range l.Series {
+		// Missing values break the curve into separate parts.
+		var px, py []float64
+		flush := func() {
+			c.Polyline(px, py, Style{Stroke: s.Color, Width: 2.5})
+			px, py = px[:0], py[:0]
+		}
+		for i := range l.X {
+			if i >= len(s.Values) || !valid(s.Values[i]) {
+				flush()
+				continue
+			}
+			px = append(px, xs[i])
+			py = append(py, a.pos(s.Values[i], f.top, f.bottom))
+		}
+		flush()
+		for i := range l.X {
+			if i < len(s.Values) && valid(s.Values[i]) {
+				y := a.pos(s.Values[i], f.top, f.bottom)
+				c.Circle(xs[i], y, 4.5, Style{
+					Fill: s.Color, Stroke: s.Color.Darken(0.4), Width: 1.5,
+				})
+				drawNote(c, xs[i], y-4.5, s.note(i), s.Color)
+			} else {
+				drawNote(c, xs[i], f.bottom, s.note(i), s.Color)
+			}
+		}
+	}
+	drawLegend(c, f, names, colors, nil)
+}
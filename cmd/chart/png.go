@@ -0,0 +1,233 @@
+package chart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+var fonts struct {
+	once    sync.Once
+	regular *opentype.Font
+	bold    *opentype.Font
+	mu      sync.Mutex
+	faces   map[faceKey]font.Face
+}
+
+type faceKey struct {
+	size float64
+	bold bool
+}
+
+// face returns the Go font face for the size, in pixels.
+func face(size float64, bold bool) font.Face {
+	fonts.once.Do(func() {
+		var err error
+		if fonts.regular, err = opentype.Parse(goregular.TTF); err != nil {
+			panic(err)
+		}
+		if fonts.bold, err = opentype.Parse(gobold.TTF); err != nil {
+			panic(err)
+		}
+		fonts.faces = map[faceKey]font.Face{}
+	})
+	fonts.mu.Lock()
+	defer fonts.mu.Unlock()
+	key := faceKey{size, bold}
+	if f, ok := fonts.faces[key]; ok {
+		return f
+	}
+	fnt := fonts.regular
+	if bold {
+		fnt = fonts.bold
+	}
+	f, err := opentype.NewFace(fnt, &opentype.FaceOptions{
+		Size: size, DPI: 72, Hinting: font.HintingFull,
+	})
+	if err != nil {
+		panic(err)
+	}
+	fonts.faces[key] = f
+	return f
+}
+
+func measure(text string, size float64, bold bool) float64 {
+	f := face(size, bold)
+	fonts.mu.Lock()
+	defer fonts.mu.Unlock()
+	return float64(font.MeasureString(f, text)) / 64
+}
+
+type pngCanvas struct {
+	img   *image.RGBA
+	scale float64
+	r     vector.Rasterizer
+}
+
+func nrgba(c Color) image.Image {
+	return image.NewUniform(color.NRGBA{c.R, c.G, c.B, c.A})
+}
+
+// fill draws a closed path made of the points. Only the bounding box of the
+// path is rasterized, which keeps small shapes cheap.
+func (c *pngCanvas) fill(xs, ys []float64, col Color) {
+	if len(xs) < 3 || col.A == 0 {
+		return
+	}
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	for i := range xs {
+		minx, maxx = math.Min(minx, xs[i]), math.Max(maxx, xs[i])
+		miny, maxy = math.Min(miny, ys[i]), math.Max(maxy, ys[i])
+	}
+	b := image.Rect(
+		int(math.Floor(minx*c.scale)), int(math.Floor(miny*c.scale)),
+		int(math.Ceil(maxx*c.scale)), int(math.Ceil(maxy*c.scale)),
+	).Intersect(c.img.Bounds())
+	if b.Empty() {
+		return
+	}
+	ox, oy := float64(b.Min.X), float64(b.Min.Y)
+	c.r.Reset(b.Dx(), b.Dy())
+	c.r.MoveTo(float32(xs[0]*c.scale-ox), float32(ys[0]*c.scale-oy))
+	for i := 1; i < len(xs); i++ {
+		c.r.LineTo(float32(xs[i]*c.scale-ox), float32(ys[i]*c.scale-oy))
+	}
+	c.r.ClosePath()
+	c.r.Draw(c.img, b, nrgba(col), image.Point{})
+}
+
+// segment draws a single line segment as a thin quad.
+func (c *pngCanvas) segment(x1, y1, x2, y2, width float64, col Color) {
+	dx, dy := x2-x1, y2-y1
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return
+	}
+	// Keep hairlines visible, like the other renderers do.
+	width = math.Max(width, 1/c.scale)
+	nx, ny := -dy/l*width/2, dx/l*width/2
+	c.fill(
+		[]float64{x1 + nx, x2 + nx, x2 - nx, x1 - nx},
+		[]float64{y1 + ny, y2 + ny, y2 - ny, y1 - ny},
+		col,
+	)
+}
+
+func (c *pngCanvas) Rect(x, y, w, h float64, s Style) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	c.fill([]float64{x, x + w, x + w, x}, []float64{y, y, y + h, y + h},
+		s.Fill)
+	if s.Stroke.A != 0 && s.Width > 0 {
+		hw := s.Width / 2
+		c.segment(x-hw, y, x+w+hw, y, s.Width, s.Stroke)
+		c.segment(x-hw, y+h, x+w+hw, y+h, s.Width, s.Stroke)
+		c.segment(x, y, x, y+h, s.Width, s.Stroke)
+		c.segment(x+w, y, x+w, y+h, s.Width, s.Stroke)
+	}
+}
+
+func (c *pngCanvas) Line(x1, y1, x2, y2 float64, s Style) {
+	c.segment(x1, y1, x2, y2, s.Width, s.Stroke)
+}
+
+func (c *pngCanvas) Polyline(xs, ys []float64, s Style) {
+	for i := 1; i < len(xs); i++ {
+		c.segment(xs[i-1], ys[i-1], xs[i], ys[i], s.Width, s.Stroke)
+		if i < len(xs)-1 {
+			// round joins
+			c.Circle(xs[i], ys[i], s.Width/2, Style{Fill: s.Stroke})
+		}
+	}
+}
+
+func (c *pngCanvas) Circle(x, y, r float64, s Style) {
+	const n = 32
+	xs, ys := make([]float64, n), make([]float64, n)
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / n
+		xs[i], ys[i] = x+r*math.Cos(a), y+r*math.Sin(a)
+	}
+	c.fill(xs, ys, s.Fill)
+	if s.Stroke.A != 0 && s.Width > 0 {
+		for i := 0; i < n; i++ {
+			j := (i + 1) % n
+			c.segment(xs[i], ys[i], xs[j], ys[j], s.Width, s.Stroke)
+		}
+	}
+}
+
+func (c *pngCanvas) Text(x, y float64, text string, s TextStyle) {
+	f := face(s.Size*c.scale, s.Bold)
+	fonts.mu.Lock()
+	defer fonts.mu.Unlock()
+	m := f.Metrics()
+	ascent := float64(m.Ascent) / 64
+	descent := float64(m.Descent) / 64
+	width := float64(font.MeasureString(f, text)) / 64
+
+	// Draw the text onto its own image, then place it.
+	w, h := int(math.Ceil(width))+2, int(math.Ceil(ascent+descent))+2
+	tmp := image.NewRGBA(image.Rect(0, 0, w, h))
+	d := font.Drawer{
+		Dst:  tmp,
+		Src:  nrgba(s.Color),
+		Face: f,
+		Dot:  fixed.P(1, int(math.Round(ascent))+1),
+	}
+	d.DrawString(text)
+
+	var off float64
+	switch s.Anchor {
+	case Middle:
+		off = -width / 2
+	case End:
+		off = -width
+	}
+	px, py := x*c.scale, y*c.scale
+	var src *image.RGBA
+	var at image.Point
+	if s.Rotated {
+		// Rotate a quarter turn counterclockwise.
+		src = image.NewRGBA(image.Rect(0, 0, h, w))
+		for sy := 0; sy < h; sy++ {
+			for sx := 0; sx < w; sx++ {
+				src.SetRGBA(sy, w-1-sx, tmp.RGBAAt(sx, sy))
+			}
+		}
+		at = image.Pt(int(math.Round(px-float64(h)/2)),
+			int(math.Round(py-float64(w)-off)))
+	} else {
+		src = tmp
+		at = image.Pt(int(math.Round(px+off)),
+			int(math.Round(py-float64(h)/2)))
+	}
+	draw.Draw(c.img, src.Bounds().Add(at), src, image.Point{}, draw.Over)
+}
+
+// PNG returns the chart as a PNG image. The scale is the number of pixels
+// per canvas unit.
+func PNG(ch Chart, scale float64) ([]byte, error) {
+	w, h := int(Width*scale), int(Height*scale)
+	c := &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, w, h)), scale: scale}
+	draw.Draw(c.img, c.img.Bounds(), nrgba(White), image.Point{}, draw.Src)
+	ch.Draw(c)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package chart
+
+import (
+	"math"
+	"strconv"
+)
+
+// Curve is a named set of values at percentiles, such as 99.9.
+type Curve struct {
+	Name        string
+	Color       Color
+	Percentiles []float64
+	Values      []float64
+}
+
+// Spectrum is a percentile spectrum, with a curve for each series. The
+// x-axis is the number of nines in the percentile, so 90, 99 and 99.9 are
+// evenly spaced.
+type Spectrum struct {
+	Title  string
+	XTitle string
+	YTitle string
+	Curves []Curve
+	Log    bool
+}
+
+// nines returns the position of the percentile on the x-axis.
+func nines(p float64) float64 {
+	return -math.Log10(1 - p/100)
+}
+
+// ninesLabel returns the percentile with n nines, such as "99.9%".
+func ninesLabel(n int) string {
+	if n == 0 {
+		return "0%"
+	}
+	return strconv.FormatFloat(100-100/math.Pow(10, float64(n)), 'f', -1,
+		64) + "%"
+}
+
+// Draw draws the percentile spectrum onto the canvas.
+func (sp *Spectrum) Draw(c Canvas) {
+	var names []string
+	var colors []Color
+	var values []float64
+	var last float64
+	for _, cv := range sp.Curves {
+		names = append(names, cv.Name)
+		colors = append(colors, cv.Color)
+		values = append(values, cv.Values...)
+		for _, p := range cv.Percentiles {
+			if p < 100 {
+				last = math.Max(last, nines(p))
+			}
+		}
+	}
+	a := newAxis(values, sp.Log)
+	f := newFrame(a, names)
+	drawAxis(c, f, a, sp.Title, sp.XTitle, sp.YTitle)
+
+	width := math.Max(math.Ceil(last), 1)
+	xpos := func(p float64) float64 {
+		return f.left + (f.right-f.left)*nines(p)/width
+	}
+	for n := 0; n <= int(width); n++ {
+		x := f.left + (f.right-f.left)*float64(n)/width
+		c.Line(x, f.top, x, f.bottom, Style{Stroke: Gray.Alpha(0.3), Width: 0.5})
+		c.Text(x, f.bottom+22, ninesLabel(n), TextStyle{
+			Size: tickSize, Color: Black, Anchor: Middle,
+		})
+	}
+	// The median is between the first two lines.
+	c.Text(xpos(50), f.bottom+22, "50%", TextStyle{
+		Size: minorSize + 2, Color: Gray, Anchor: Middle,
+	})
+	valid := func(p, v float64) bool {
+		return p >= 0 && p < 100 && !math.IsNaN(v) && (!sp.Log || v > 0)
+	}
+	for _, cv := range sp.Curves {
+		var px, py []float64
+		for i, p := range cv.Percentiles {
+			if i < len(cv.Values) && valid(p, cv.Values[i]) {
+				px = append(px, xpos(p))
+				py = append(py, a.pos(cv.Values[i], f.top, f.bottom))
+			}
+		}
+		c.Polyline(px, py, Style{Stroke: cv.Color, Width: 2.5})
+		for i := range px {
+			c.Circle(px[i], py[i], 3, Style{
+				Fill: cv.Color, Stroke: cv.Color.Darken(0.4), Width: 1,
+			})
+		}
+	}
+	drawLegend(c, f, names, colors, nil)
+}
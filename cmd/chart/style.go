@@ -0,0 +1,183 @@
+package chart
+
+import (
+	"math"
+	"os"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/jsonc"
+)
+
+// Palette are the colors of the series that have none in the config, in the
+// order that they're used.
+var Palette = []string{
+	"#ff7f0e", "#d62728", "#1f77b4", "#e64098", "#8c564b", "#2ca02c",
+	"#9467bd", "#17becf", "#bcbd22", "#7f7f7f",
+}
+
+// hatches are added to the palette colors once they have all been used, so
+// that every series still looks different.
+var hatches = []string{"//", "..", "\\\\", "xx", "--", "||", "++"}
+
+// SeriesStyle is how a series is drawn. The hatch is a pattern of lines
+// drawn over bars, in the form that matplotlib uses, such as "//" or "..".
+type SeriesStyle struct {
+	Name  string // display name
+	Color string // such as "#ff7f0e"
+	Hatch string
+}
+
+// Styles are the styles of the caches, which are in the "caches" section of
+// the config, and a palette for the caches that are not.
+type Styles struct {
+	Caches  map[string]SeriesStyle
+	Palette []string
+}
+
+// ReadStyles reads the styles from the config file. A missing file has no
+// styles, which leaves every series to the palette.
+func ReadStyles(path string) (*Styles, error) {
+	st := &Styles{Caches: map[string]SeriesStyle{}, Palette: Palette}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := jsonc.ToJSONInPlace(data)
+	gjson.GetBytes(config, "caches").ForEach(func(key, value gjson.Result) bool {
+		st.Caches[key.String()] = SeriesStyle{
+			Name:  value.Get("name").String(),
+			Color: value.Get("color").String(),
+			Hatch: value.Get("hatch").String(),
+		}
+		return true
+	})
+	if palette := gjson.GetBytes(config, "palette").Array(); len(palette) > 0 {
+		st.Palette = nil
+		for _, color := range palette {
+			st.Palette = append(st.Palette, color.String())
+		}
+	}
+	return st, nil
+}
+
+// Assign returns the style of each series. A series is found in the config
+// by its own name, or else by the name of its cache, such as "redis" for
+// "redis (host1)". Series without a color, or with the same look as one
+// before them, get the next palette color that no other series has.
+func (st *Styles) Assign(series, caches []string) []SeriesStyle {
+	styles := make([]SeriesStyle, len(series))
+	used := map[SeriesStyle]bool{}
+	colors := map[string]bool{}
+	var unstyled []int
+	for i, name := range series {
+		style, ok := st.Caches[name]
+		if !ok {
+			style = st.Caches[caches[i]]
+			if style.Name != "" && strings.HasPrefix(name, caches[i]) {
+				style.Name += name[len(caches[i]):]
+			} else {
+				style.Name = ""
+			}
+		}
+		if style.Name == "" {
+			style.Name = name
+		}
+		look := SeriesStyle{Color: style.Color, Hatch: style.Hatch}
+		if style.Color == "" || used[look] {
+			unstyled = append(unstyled, i)
+		} else {
+			used[look] = true
+			colors[look.Color] = true
+		}
+		styles[i] = style
+	}
+	// Each pass over the palette adds a hatch, until there are none left.
+	// The first pass skips colors that any series has.
+	looks := len(st.Palette) * (len(hatches) + 1)
+	next := 0
+	for _, i := range unstyled {
+		var look SeriesStyle
+		for tries := 0; tries < looks; tries++ {
+			look.Color = st.Palette[next%len(st.Palette)]
+			look.Hatch = ""
+			if n := next / len(st.Palette) % (len(hatches) + 1); n > 0 {
+				look.Hatch = hatches[n-1]
+			}
+			next++
+			if !used[look] && (look.Hatch != "" || !colors[look.Color]) {
+				break
+			}
+		}
+		used[look] = true
+		colors[look.Color] = true
+		styles[i].Color, styles[i].Hatch = look.Color, look.Hatch
+	}
+	return styles
+}
+
+// drawHatch draws the hatch pattern over a rectangle. Each kind of line is
+// closer together the more times it's repeated, such as "///" over "/".
+func drawHatch(c Canvas, x, y, w, h float64, hatch string, color Color) {
+	if hatch == "" || w <= 0 || h <= 0 {
+		return
+	}
+	s := Style{Stroke: color, Width: 1}
+	// lines draws the lines where px*dx + py*dy = k, at every step of k.
+	lines := func(dx, dy float64, n int) {
+		step := 10 / float64(n)
+		lo := math.Min(x*dx+y*dy, (x+w)*dx+(y+h)*dy)
+		lo = math.Min(lo, math.Min(x*dx+(y+h)*dy, (x+w)*dx+y*dy))
+		hi := math.Max(x*dx+y*dy, (x+w)*dx+(y+h)*dy)
+		hi = math.Max(hi, math.Max(x*dx+(y+h)*dy, (x+w)*dx+y*dy))
+		for k := math.Ceil(lo/step) * step; k <= hi; k += step {
+			switch {
+			case dy == 0:
+				c.Line(k, y, k, y+h, s)
+			case dx == 0:
+				c.Line(x, k, x+w, k, s)
+			default:
+				// py = (k - px*dx) / dy, kept within the rectangle.
+				x0, x1 := x, x+w
+				ya, yb := (k-y*dy)/dx, (k-(y+h)*dy)/dx
+				x0 = math.Max(x0, math.Min(ya, yb))
+				x1 = math.Min(x1, math.Max(ya, yb))
+				if x0 < x1 {
+					c.Line(x0, (k-x0*dx)/dy, x1, (k-x1*dx)/dy, s)
+				}
+			}
+		}
+	}
+	count := func(chars string) int {
+		var n int
+		for _, ch := range hatch {
+			if strings.ContainsRune(chars, ch) {
+				n++
+			}
+		}
+		return n
+	}
+	if n := count("/x"); n > 0 {
+		lines(1, 1, n)
+	}
+	if n := count("\\x"); n > 0 {
+		lines(1, -1, n)
+	}
+	if n := count("|+"); n > 0 {
+		lines(1, 0, n)
+	}
+	if n := count("-+"); n > 0 {
+		lines(0, 1, n)
+	}
+	if n := count(".oO*"); n > 0 {
+		step := 10 / float64(n)
+		for py := math.Ceil(y/step)*step + step/2; py < y+h; py += step {
+			for px := math.Ceil(x/step)*step + step/2; px < x+w; px += step {
+				c.Circle(px, py, 1, Style{Fill: color})
+			}
+		}
+	}
+}
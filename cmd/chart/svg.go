@@ -0,0 +1,104 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strconv"
+)
+
+// FontFamily is the font used for text in SVG output. Viewers fall back to
+// a sans-serif font when it's not installed.
+var FontFamily = "Futura"
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (c *svgCanvas) style(s Style) string {
+	var out string
+	if s.Fill.A == 0 {
+		out += ` fill="none"`
+	} else {
+		out += ` fill="` + s.Fill.hex() + `"`
+		if s.Fill.A != 255 {
+			out += ` fill-opacity="` + num(float64(s.Fill.A)/255) + `"`
+		}
+	}
+	if s.Stroke.A != 0 && s.Width > 0 {
+		out += ` stroke="` + s.Stroke.hex() + `" stroke-width="` +
+			num(s.Width) + `"`
+		if s.Stroke.A != 255 {
+			out += ` stroke-opacity="` + num(float64(s.Stroke.A)/255) + `"`
+		}
+	}
+	return out
+}
+
+func (c *svgCanvas) Rect(x, y, w, h float64, s Style) {
+	fmt.Fprintf(&c.buf, `<rect x="%s" y="%s" width="%s" height="%s"%s/>`+"\n",
+		num(x), num(y), num(w), num(h), c.style(s))
+}
+
+func (c *svgCanvas) Line(x1, y1, x2, y2 float64, s Style) {
+	fmt.Fprintf(&c.buf, `<line x1="%s" y1="%s" x2="%s" y2="%s"%s/>`+"\n",
+		num(x1), num(y1), num(x2), num(y2), c.style(s))
+}
+
+func (c *svgCanvas) Polyline(xs, ys []float64, s Style) {
+	var points string
+	for i := range xs {
+		if i > 0 {
+			points += " "
+		}
+		points += num(xs[i]) + "," + num(ys[i])
+	}
+	s.Fill = None
+	fmt.Fprintf(&c.buf, `<polyline points="%s"%s/>`+"\n", points, c.style(s))
+}
+
+func (c *svgCanvas) Circle(x, y, r float64, s Style) {
+	fmt.Fprintf(&c.buf, `<circle cx="%s" cy="%s" r="%s"%s/>`+"\n",
+		num(x), num(y), num(r), c.style(s))
+}
+
+func (c *svgCanvas) Text(x, y float64, text string, s TextStyle) {
+	anchor := "start"
+	switch s.Anchor {
+	case Middle:
+		anchor = "middle"
+	case End:
+		anchor = "end"
+	}
+	attrs := fmt.Sprintf(`x="%s" y="%s" font-size="%s" fill="%s" `+
+		`text-anchor="%s" dominant-baseline="central"`,
+		num(x), num(y), num(s.Size), s.Color.hex(), anchor)
+	if s.Color.A != 255 {
+		attrs += ` fill-opacity="` + num(float64(s.Color.A)/255) + `"`
+	}
+	if s.Bold {
+		attrs += ` font-weight="bold"`
+	}
+	if s.Rotated {
+		attrs += fmt.Sprintf(` transform="rotate(-90 %s %s)"`, num(x), num(y))
+	}
+	fmt.Fprintf(&c.buf, "<text %s>%s</text>\n", attrs,
+		html.EscapeString(text))
+}
+
+// SVG returns the chart as an SVG document.
+func SVG(ch Chart) []byte {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.buf, `<svg xmlns="http://www.w3.org/2000/svg" `+
+		`width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`font-family="%s, sans-serif">`+"\n",
+		Width, Height, Width, Height, html.EscapeString(FontFamily))
+	c.Rect(0, 0, Width, Height, Style{Fill: White})
+	ch.Draw(c)
+	c.buf.WriteString("</svg>\n")
+	return c.buf.Bytes()
+}
@@ -0,0 +1,216 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// table is the data of a chart as rows of cells, with the first column
+// naming the row and the first row naming the columns.
+type table struct {
+	title   string
+	caption string
+	rows    [][]string
+	notes   []string
+}
+
+// Table returns the data of the chart as a table of text, with aligned
+// columns, or as markdown. Each series is a row and each x-axis position is
+// a column. Heatmaps keep their rows and columns, and spectrums have a
+// column for each percentile. Missing values are shown as "-".
+func Table(ch Chart, markdown bool) []byte {
+	var t table
+	switch ch := ch.(type) {
+	case *Bar:
+		t = seriesTable(ch.Title, ch.YTitle, ch.XTitle, ch.X, ch.Series,
+			false)
+	case *Line:
+		t = seriesTable(ch.Title, ch.YTitle, ch.XTitle, ch.X, ch.Series,
+			false)
+	case *Box:
+		t = seriesTable(ch.Title, ch.YTitle, ch.XTitle, ch.X, ch.Series,
+			true)
+	case *Heatmap:
+		t = heatmapTable(ch)
+	case *Spectrum:
+		t = spectrumTable(ch)
+	default:
+		panic(fmt.Sprintf("chart: no table for %T", ch))
+	}
+	if markdown {
+		return t.markdown()
+	}
+	return t.text()
+}
+
+// cell returns the text of a value, or "-" when it's missing.
+func cell(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return Label(v)
+}
+
+// seriesTable returns the table of the series. Values with a range, such as
+// error bars, are followed by it, and box plots show the quartiles instead.
+func seriesTable(title, ytitle, xtitle string, x []string, series []Series,
+	quartiles bool,
+) table {
+	t := table{title: title, caption: ytitle}
+	t.rows = append(t.rows, append([]string{xtitle}, x...))
+	for _, s := range series {
+		row := []string{s.Name}
+		for i := range x {
+			text := seriesCell(s, i, quartiles)
+			if note := s.note(i); note != "" {
+				t.notes = append(t.notes,
+					s.Name+" at "+xtitle+" "+x[i]+": "+note)
+				text += " *"
+			}
+			row = append(row, text)
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// seriesCell returns the text of the value of the series at i, followed by
+// its range, or by its quartiles for box plots.
+func seriesCell(s Series, i int, quartiles bool) string {
+	if i >= len(s.Values) || math.IsNaN(s.Values[i]) {
+		return "-"
+	}
+	text := Label(s.Values[i])
+	if quartiles {
+		s = Series{Low: s.Q1, High: s.Q3}
+	}
+	if lo, hi, ok := s.span(i); ok {
+		text += " [" + Label(lo) + ", " + Label(hi) + "]"
+	}
+	return text
+}
+
+// heatmapTable returns the table of the heatmap, with a row for each y-axis
+// position. Cells with text show it on one line instead of the value.
+func heatmapTable(h *Heatmap) table {
+	t := table{title: h.Title, caption: h.Subtitle}
+	t.rows = append(t.rows, append([]string{h.YTitle + " / " + h.XTitle},
+		h.X...))
+	for y, name := range h.Y {
+		row := []string{name}
+		for x := range h.X {
+			text := "-"
+			if y < len(h.Values) && x < len(h.Values[y]) {
+				text = cell(h.Values[y][x])
+			}
+			var lines string
+			if y < len(h.Text) && x < len(h.Text[y]) {
+				lines = h.Text[y][x]
+			}
+			if lines != "" {
+				text = strings.ReplaceAll(lines, "\n", " ")
+			}
+			row = append(row, text)
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// spectrumTable returns the table of the spectrum, with a column for every
+// percentile of any curve.
+func spectrumTable(sp *Spectrum) table {
+	t := table{title: sp.Title, caption: sp.YTitle}
+	var ps []float64
+	seen := map[float64]bool{}
+	for _, cv := range sp.Curves {
+		for _, p := range cv.Percentiles {
+			if !seen[p] {
+				seen[p] = true
+				ps = append(ps, p)
+			}
+		}
+	}
+	sort.Float64s(ps)
+	header := []string{sp.XTitle}
+	for _, p := range ps {
+		header = append(header, strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	t.rows = append(t.rows, header)
+	for _, cv := range sp.Curves {
+		row := []string{cv.Name}
+		for _, p := range ps {
+			text := "-"
+			for i, q := range cv.Percentiles {
+				if q == p && i < len(cv.Values) {
+					text = cell(cv.Values[i])
+				}
+			}
+			row = append(row, text)
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// text returns the table with aligned columns. The first column is aligned
+// to the left and the values to the right.
+func (t table) text() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n%s\n\n", t.title, t.caption)
+	var widths []int
+	for _, row := range t.rows {
+		for i, c := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], len(c))
+		}
+	}
+	for _, row := range t.rows {
+		var line string
+		for i, c := range row {
+			if i == 0 {
+				line += fmt.Sprintf("%-*s", widths[i], c)
+			} else {
+				line += fmt.Sprintf("  %*s", widths[i], c)
+			}
+		}
+		fmt.Fprintf(&buf, "%s\n", strings.TrimRight(line, " "))
+	}
+	for _, note := range t.notes {
+		fmt.Fprintf(&buf, "* %s\n", note)
+	}
+	fmt.Fprintf(&buf, "\n")
+	return buf.Bytes()
+}
+
+// markdown returns the table in markdown, with the values aligned to the
+// right.
+func (t table) markdown() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "**%s**\n\n%s\n\n", t.title, t.caption)
+	for i, row := range t.rows {
+		var cells []string
+		for _, c := range row {
+			cells = append(cells, strings.ReplaceAll(c, "|", "\\|"))
+		}
+		fmt.Fprintf(&buf, "| %s |\n", strings.Join(cells, " | "))
+		if i == 0 {
+			fmt.Fprintf(&buf, "| --- |%s\n",
+				strings.Repeat(" ---: |", len(row)-1))
+		}
+	}
+	if len(t.notes) > 0 {
+		fmt.Fprintf(&buf, "\n")
+	}
+	for _, note := range t.notes {
+		fmt.Fprintf(&buf, "\\* %s\n", note)
+	}
+	fmt.Fprintf(&buf, "\n")
+	return buf.Bytes()
+}
@@ -4,10 +4,12 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"sync"
 
-	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
+	"github.com/tidwall/cache-benchmarks/results"
 )
 
 var path string
@@ -16,81 +18,172 @@ var threads int
 var pipeline int
 var runs int
 var perf string
+var jobs int = 1
+
+// group is a single benchmark configuration along with the number of runs
+// that were performed for it.
+type group struct {
+	key  results.Key
+	runs int
+}
 
 func main() {
 	flag.StringVar(&path, "path", path, "path")
-	flag.StringVar(&prog, "prog", prog, "prog")
+	flag.StringVar(&prog, "prog", prog, "prog (omit to discover all)")
 	flag.IntVar(&threads, "threads", threads, "threads")
 	flag.IntVar(&pipeline, "pipeline", pipeline, "pipeline")
 	flag.StringVar(&perf, "perf", perf, "perf")
 	flag.IntVar(&runs, "runs", runs, "runs")
+	flag.IntVar(&jobs, "jobs", jobs, "number of groups to process in parallel")
 	flag.Parse()
 
 	path += "/runs"
 
 	// println(prog, threads, pipeline, perf, runs)
 
-	choose("median")
-	choose("best")
-	choose("worst")
-	choose("average")
+	var groups []group
+	if prog == "" {
+		// No program was provided. Discover every configuration that has
+		// run files in the directory.
+		groups = discover()
+		if len(groups) == 0 {
+			fmt.Fprintf(os.Stderr, "no run files found in %s\n", path)
+			os.Exit(1)
+		}
+	} else {
+		key := results.Key{
+			Cache: prog, Threads: threads, Pipeline: pipeline, Perf: perf,
+		}
+		groups = append(groups, group{key, runs})
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	var wg sync.WaitGroup
+	ch := make(chan group)
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range ch {
+				choose(g, "median")
+				choose(g, "best")
+				choose(g, "worst")
+				choose(g, "average")
+			}
+		}()
+	}
+	for _, g := range groups {
+		ch <- g
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// discover parses the names of all run files in the path and groups them by
+// configuration. The optional --threads, --pipeline, and --perf flags narrow
+// the groups that are returned.
+func discover() []group {
+	fis, err := os.ReadDir(path)
+	if err != nil {
+		panic(err)
+	}
+	found := map[results.Key]map[int]bool{}
+	for _, fi := range fis {
+		k, srun, ok := results.ParseFile(fi.Name())
+		if !ok {
+			continue
+		}
+		run, err := strconv.Atoi(srun)
+		if err != nil {
+			// aggregate file
+			continue
+		}
+		if (threads != 0 && k.Threads != threads) ||
+			(pipeline != 0 && k.Pipeline != pipeline) ||
+			(perf != "" && k.Perf != perf) {
+			continue
+		}
+		if found[k] == nil {
+			found[k] = map[int]bool{}
+		}
+		found[k][run] = true
+	}
+	var groups []group
+	for k, nums := range found {
+		n := len(nums)
+		if runs != 0 {
+			n = runs
+		}
+		for run := 1; run <= n; run++ {
+			if !nums[run] {
+				fmt.Fprintf(os.Stderr, "skipping %s threads=%d pipeline=%d "+
+					"perf=%s: missing run %d\n",
+					k.Cache, k.Threads, k.Pipeline, k.Perf, run)
+				n = 0
+				break
+			}
+		}
+		if n > 0 {
+			groups = append(groups, group{k, n})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i].key, groups[j].key
+		if a.Cache != b.Cache {
+			return a.Cache < b.Cache
+		}
+		if a.Threads != b.Threads {
+			return a.Threads < b.Threads
+		}
+		if a.Pipeline != b.Pipeline {
+			return a.Pipeline < b.Pipeline
+		}
+		return a.Perf < b.Perf
+	})
+	return groups
 }
 
-func runfile(run int) string {
-	return fmt.Sprintf("%s/bench_%s-threads_%d-pipeline_%d-perf_%s-run_%d.json",
-		path, prog, threads, pipeline, perf, run+1)
+func runfile(g group, run int) string {
+	return filepath.Join(path, g.key.File(fmt.Sprint(run+1)))
 }
 
-func resultfile(choose string) string {
-	return fmt.Sprintf("%s/bench_%s-threads_%d-pipeline_%d-perf_%s-run_%s.json",
-		path, prog, threads, pipeline, perf, choose)
+func resultfile(g group, choose string) string {
+	return filepath.Join(path, g.key.File(choose))
 }
 
-func runjson(run int) string {
-	data, err := os.ReadFile(runfile(run))
+func runread(g group, run int) results.Run {
+	r, err := results.ReadRun(runfile(g, run))
 	if err != nil {
 		panic(err)
 	}
-	return string(data)
-}
-
-func cleanperf(json gjson.Result) gjson.Result {
-	if !json.Get("cycles").Exists() {
-		return json
-	}
-	raw := json.Raw
-	raw, _ = sjson.SetRaw(raw, "cpu_utilized", fmt.Sprintf("%.3f", json.Get("cpu_utilized").Float()))
-	raw, _ = sjson.SetRaw(raw, "cycles", fmt.Sprintf("%.0f", json.Get("cycles").Float()))
-	raw, _ = sjson.SetRaw(raw, "instructions", fmt.Sprintf("%.0f", json.Get("instructions").Float()))
-	raw, _ = sjson.SetRaw(raw, "branches", fmt.Sprintf("%.0f", json.Get("branches").Float()))
-	raw, _ = sjson.SetRaw(raw, "branch_misses", fmt.Sprintf("%.0f", json.Get("branch_misses").Float()))
-	raw, _ = sjson.SetRaw(raw, "page_faults", fmt.Sprintf("%.0f", json.Get("page_faults").Float()))
-	return gjson.Parse(raw)
+	return r
 }
 
-func choose(kind string) {
-	var tinfo gjson.Result
-	var gets []gjson.Result
-	var sets []gjson.Result
-	var perf []gjson.Result
+func choose(g group, kind string) {
+	runs := g.runs
+	var tinfo results.Info
+	var gets []results.Stats
+	var sets []results.Stats
+	var perf []results.Perf
 	for run := 0; run < runs; run++ {
-		json := runjson(run)
-		gets = append(gets, gjson.Get(json, "gets"))
-		sets = append(sets, gjson.Get(json, "sets"))
-		perf = append(perf, gjson.Get(json, "perf"))
-		tinfo = gjson.Get(json, "info")
+		r := runread(g, run)
+		gets = append(gets, r.Gets)
+		sets = append(sets, r.Sets)
+		perf = append(perf, r.Perf)
+		tinfo = r.Info
 	}
-	var tgets gjson.Result
-	var tsets gjson.Result
-	var tperf gjson.Result
+	var tgets results.Stats
+	var tsets results.Stats
+	var tperf results.Perf
 	sort.Slice(gets, func(i, j int) bool {
-		return gets[i].Get("opsec").Float() < gets[j].Get("opsec").Float()
+		return gets[i].Opsec < gets[j].Opsec
 	})
 	sort.Slice(sets, func(i, j int) bool {
-		return sets[i].Get("opsec").Float() < sets[j].Get("opsec").Float()
+		return sets[i].Opsec < sets[j].Opsec
 	})
-	sort.Slice(sets, func(i, j int) bool {
-		return perf[i].Get("cycles").Int() > perf[j].Get("cycles").Int()
+	sort.Slice(perf, func(i, j int) bool {
+		return perf[i].Cycles > perf[j].Cycles
 	})
 	if runs > 10 {
 		// remove outliers
@@ -110,7 +203,9 @@ func choose(kind string) {
 		case "worst":
 			m = 0
 		case "median":
-			m = runs/2 + 1
+			// The lower of the two middle runs when there are an even
+			// number of them.
+			m = (runs - 1) / 2
 		default:
 			panic("invalid kind: " + kind)
 		}
@@ -118,108 +213,28 @@ func choose(kind string) {
 		tsets = sets[m]
 		tperf = perf[m]
 	}
-	raw, _ := sjson.Set(tinfo.Raw, "kind", kind)
-	tinfo = gjson.Parse(raw)
-	out := "" +
-		"{\n" +
-		"  \"info\": " + tinfo.Get("@ugly").Raw + ",\n" +
-		"  \"sets\": " + tsets.Get("@ugly").Raw + ",\n" +
-		"  \"gets\": " + tgets.Get("@ugly").Raw + ",\n" +
-		"  \"perf\": " + cleanperf(tperf).Get("@ugly").Raw + "\n" +
-		"}\n"
-	err := os.WriteFile(resultfile(kind), []byte(out), 0666)
+	tinfo.Kind = kind
+	out := results.Run{Info: tinfo, Sets: tsets, Gets: tgets, Perf: tperf}
+	err := results.WriteRun(resultfile(g, kind), out)
 	if err != nil {
 		panic(err)
 	}
 }
 
-func sumops(json gjson.Result, add gjson.Result) gjson.Result {
-	raw := json.Raw
-	raw, _ = sjson.Set(raw, "opsec", json.Get("opsec").Float()+add.Get("opsec").Float())
-	raw, _ = sjson.Set(raw, "mbsec", json.Get("mbsec").Float()+add.Get("mbsec").Float())
-	raw, _ = sjson.Set(raw, "latency.min", json.Get("latency.min").Float()+add.Get("latency.min").Float())
-	raw, _ = sjson.Set(raw, "latency.max", json.Get("latency.max").Float()+add.Get("latency.max").Float())
-	raw, _ = sjson.Set(raw, "latency.avg", json.Get("latency.avg").Float()+add.Get("latency.avg").Float())
-	raw, _ = sjson.Set(raw, "latency.p50_00", json.Get("latency.p50_00").Float()+add.Get("latency.p50_00").Float())
-	raw, _ = sjson.Set(raw, "latency.p90_00", json.Get("latency.p90_00").Float()+add.Get("latency.p90_00").Float())
-	raw, _ = sjson.Set(raw, "latency.p99_00", json.Get("latency.p99_00").Float()+add.Get("latency.p99_00").Float())
-	raw, _ = sjson.Set(raw, "latency.p99_90", json.Get("latency.p99_90").Float()+add.Get("latency.p99_90").Float())
-	raw, _ = sjson.Set(raw, "latency.p99_90", json.Get("latency.p99_90").Float()+add.Get("latency.p99_90").Float())
-	raw, _ = sjson.Set(raw, "latency.p99_99", json.Get("latency.p99_99").Float()+add.Get("latency.p99_99").Float())
-	return gjson.Parse(raw)
-}
-
-func sumperf(json gjson.Result, add gjson.Result) gjson.Result {
-	if !json.Get("cycles").Exists() {
-		return json
-	}
-	raw := json.Raw
-	raw, _ = sjson.Set(raw, "cpu_utilized", json.Get("cpu_utilized").Float()+add.Get("cpu_utilized").Float())
-	raw, _ = sjson.Set(raw, "cycles", json.Get("cycles").Float()+add.Get("cycles").Float())
-	raw, _ = sjson.Set(raw, "instructions", json.Get("instructions").Float()+add.Get("instructions").Float())
-	raw, _ = sjson.Set(raw, "branches", json.Get("branches").Float()+add.Get("branches").Float())
-	raw, _ = sjson.Set(raw, "branch_misses", json.Get("branch_misses").Float()+add.Get("branch_misses").Float())
-	raw, _ = sjson.Set(raw, "page_faults", json.Get("page_faults").Float()+add.Get("page_faults").Float())
-	return gjson.Parse(raw)
-}
-
-func avgops(json gjson.Result) gjson.Result {
-	raw := json.Raw
-	raw, _ = sjson.SetRaw(raw, "opsec", fmt.Sprintf("%.3f", json.Get("opsec").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "mbsec", fmt.Sprintf("%.3f", json.Get("mbsec").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.min", fmt.Sprintf("%.3f", json.Get("latency.min").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.max", fmt.Sprintf("%.3f", json.Get("latency.max").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.avg", fmt.Sprintf("%.3f", json.Get("latency.avg").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.p50_00", fmt.Sprintf("%.3f", json.Get("latency.p50_00").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.p90_00", fmt.Sprintf("%.3f", json.Get("latency.p90_00").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.p99_00", fmt.Sprintf("%.3f", json.Get("latency.p99_00").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.p99_90", fmt.Sprintf("%.3f", json.Get("latency.p99_90").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.p99_90", fmt.Sprintf("%.3f", json.Get("latency.p99_90").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "latency.p99_99", fmt.Sprintf("%.3f", json.Get("latency.p99_99").Float()/float64(runs)))
-	return gjson.Parse(raw)
-
-}
-
-func avgperf(json gjson.Result) gjson.Result {
-	if !json.Get("cycles").Exists() {
-		return json
-	}
-	raw := json.Raw
-	raw, _ = sjson.SetRaw(raw, "cpu_utilized", fmt.Sprintf("%.3f", json.Get("cpu_utilized").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "cycles", fmt.Sprintf("%.0f", json.Get("cycles").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "instructions", fmt.Sprintf("%.0f", json.Get("instructions").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "branches", fmt.Sprintf("%.0f", json.Get("branches").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "branch_misses", fmt.Sprintf("%.0f", json.Get("branch_misses").Float()/float64(runs)))
-	raw, _ = sjson.SetRaw(raw, "page_faults", fmt.Sprintf("%.0f", json.Get("page_faults").Float()/float64(runs)))
-	return gjson.Parse(raw)
-}
-
-func calcAverage(agets []gjson.Result, asets []gjson.Result,
-	aperf []gjson.Result,
-) (tgets gjson.Result, tsets gjson.Result, tperf gjson.Result) {
+func calcAverage(agets []results.Stats, asets []results.Stats,
+	aperf []results.Perf,
+) (tgets results.Stats, tsets results.Stats, tperf results.Perf) {
+	// The sums start from the first run, as the spectrum and sizes are only
+	// kept when every run has the same percentiles and size ranges.
 	runs := len(agets)
-	for run := 0; run < runs; run++ {
-		gets := agets[run]
-		sets := asets[run]
-		perf := aperf[run]
-		if run == 0 {
-			tgets = gets
-		} else {
-			tgets = sumops(tgets, gets)
-		}
-		if run == 0 {
-			tsets = sets
-		} else {
-			tsets = sumops(tsets, sets)
-		}
-		if run == 0 {
-			tperf = perf
-		} else {
-			tperf = sumperf(tperf, perf)
-		}
+	tgets, tsets, tperf = agets[0], asets[0], aperf[0]
+	for run := 1; run < runs; run++ {
+		tgets = tgets.Add(agets[run])
+		tsets = tsets.Add(asets[run])
+		tperf = tperf.Add(aperf[run])
 	}
-	tgets = avgops(tgets)
-	tsets = avgops(tsets)
-	tperf = avgperf(tperf)
+	tgets = tgets.Div(float64(runs))
+	tsets = tsets.Div(float64(runs))
+	tperf = tperf.Div(float64(runs))
 	return tgets, tsets, tperf
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+func TestChoose(t *testing.T) {
+	seq := func(n int) []float64 {
+		var values []float64
+		for i := n; i >= 1; i-- {
+			values = append(values, float64(i))
+		}
+		return values
+	}
+	// More than 10 runs have the lowest and highest tenth left out.
+	tests := []struct {
+		opsec                        []float64
+		median, best, worst, average float64
+	}{
+		{[]float64{5, 1, 4, 2, 3}, 3, 5, 1, 3},
+		{[]float64{4, 1, 3, 2}, 2, 4, 1, 2.5},
+		{[]float64{7}, 7, 7, 7, 7},
+		{seq(12), 6, 11, 2, 6.5},
+	}
+	for _, tt := range tests {
+		path = t.TempDir()
+		key := results.Key{Cache: "valkey", Threads: 1, Pipeline: 1,
+			Perf: "no"}
+		g := group{key, len(tt.opsec)}
+		for i, v := range tt.opsec {
+			r := results.Run{Info: results.Info{
+				Cache: "valkey", Threads: 1, Pipeline: 1,
+				Connections: 1, Operations: 1,
+			}}
+			r.Gets.Opsec, r.Sets.Opsec = v, v
+			err := results.WriteRun(runfile(g, i), r)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := map[string]float64{"median": tt.median, "best": tt.best,
+			"worst": tt.worst, "average": tt.average}
+		kinds := []string{"median", "best", "worst", "average"}
+		for _, kind := range kinds {
+			choose(g, kind)
+			r, err := results.ReadRun(resultfile(g, kind))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := r.Gets.Opsec
+			if got != want[kind] || r.Sets.Opsec != got ||
+				r.Info.Kind != kind {
+				t.Errorf("%s of %v is %v, want %v", kind,
+					tt.opsec, got, want[kind])
+			}
+		}
+	}
+}
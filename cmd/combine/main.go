@@ -1,49 +1,208 @@
 package main
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
 	"strings"
+
+	"github.com/tidwall/cache-benchmarks/results"
 )
 
 var path string
+var out string
+var formats string = "json"
+var conflicts string = "error"
 
 func main() {
-	flag.StringVar(&path, "path", path, "path")
+	flag.StringVar(&path, "path", path, "comma separated list of results "+
+		"directories, each may be prefixed with a label, e.g. arm=results")
+	flag.StringVar(&out, "out", out, "output directory "+
+		"(default is the path, when only one is provided)")
+	flag.StringVar(&formats, "format", formats, "comma separated list of "+
+		"output formats: json,csv,tsv,ndjson")
+	flag.StringVar(&conflicts, "conflicts", conflicts, "how to handle "+
+		"records that differ for the same configuration: error,first,last")
 	flag.Parse()
 
+	switch conflicts {
+	case "error", "first", "last":
+	default:
+		fmt.Fprintf(os.Stderr, "invalid flag --conflicts='%s'\n", conflicts)
+		os.Exit(1)
+	}
+	paths := strings.Split(path, ",")
+	labels := make([]string, len(paths))
+	for i := range paths {
+		if j := strings.IndexByte(paths[i], '='); j != -1 {
+			labels[i], paths[i] = paths[i][:j], paths[i][j+1:]
+		}
+	}
+	if out == "" {
+		if len(paths) > 1 {
+			fmt.Fprintf(os.Stderr, "missing flag --out, which is required "+
+				"when combining multiple paths\n")
+			os.Exit(1)
+		}
+		out = paths[0]
+	}
+
+	recs, nconflicts := merge(paths, labels)
+	if nconflicts > 0 && conflicts == "error" {
+		fmt.Fprintf(os.Stderr, "%d conflicting records, use --conflicts "+
+			"to choose which to keep\n", nconflicts)
+		os.Exit(1)
+	}
+	if len(paths) == 1 && labels[0] == "" {
+		// A single unlabeled directory does not need to be tagged.
+		for i := range recs {
+			recs[i].Source = ""
+		}
+	}
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.TrimSpace(format)
+		var data []byte
+		switch format {
+		case "json":
+			data = results.EncodeOutput(recs)
+		case "csv":
+			data = encodeDelimited(recs, ',')
+		case "tsv":
+			data = encodeDelimited(recs, '\t')
+		case "ndjson":
+			data = encodeNDJSON(recs)
+		default:
+			fmt.Fprintf(os.Stderr, "invalid flag --format='%s'\n", format)
+			os.Exit(1)
+		}
+		err := os.WriteFile(out+"/output."+format, data, 0666)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// merge returns the aggregate records of the results directories, and the
+// number of conflicting records, which are kept according to --conflicts.
+func merge(paths, labels []string) ([]results.Record, int) {
+	// A record is identified by its file name, which is the configuration
+	// and aggregate, and by its origin, which is the label of the directory
+	// it was read from, or else the host and sweep that produced it.
+	// Records that share an identity must have the same data.
+	type ident struct {
+		origin, file string
+	}
+	idx := map[ident]int{}
+	var recs []results.Record
+	var from []string // directory of each record
+	var nconflicts int
+	for i, path := range paths {
+		for _, rec := range readdir(path) {
+			// Records of an unlabeled directory are only tagged with
+			// it when they have no host or sweep.
+			rec.Source = labels[i]
+			if rec.Source == "" && rec.Data.Info.Label() == "" {
+				rec.Source = path
+			}
+			id := ident{rec.Origin(), rec.File}
+			j, ok := idx[id]
+			if !ok {
+				idx[id] = len(recs)
+				recs = append(recs, rec)
+				from = append(from, path)
+				continue
+			}
+			if bytes.Equal(recs[j].Data.Encode(), rec.Data.Encode()) {
+				// same data, keep the first
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "conflict: %s differs in %s and %s\n",
+				rec.File, from[j], path)
+			nconflicts++
+			if conflicts == "last" {
+				recs[j] = rec
+				from[j] = path
+			}
+		}
+	}
+	return recs, nconflicts
+}
+
+// readdir reads the aggregate run files in the results directory.
+func readdir(path string) []results.Record {
 	fis, err := os.ReadDir(path + "/runs")
 	if err != nil {
 		panic(err)
 	}
-	out := "[\n"
-	var count int
+	var recs []results.Record
 	for _, fi := range fis {
-		if strings.Contains(fi.Name(), "average.json") ||
-			strings.Contains(fi.Name(), "best.json") ||
-			strings.Contains(fi.Name(), "median.json") ||
-			strings.Contains(fi.Name(), "worst.json") {
-			data, err := os.ReadFile(path + "/runs/" + fi.Name())
-			if err != nil {
-				panic(err)
-			}
+		_, run, ok := results.ParseFile(fi.Name())
+		if !ok {
+			continue
+		}
+		switch run {
+		case "average", "best", "median", "worst":
+		default:
+			continue
+		}
+		r, err := results.ReadRun(path + "/runs/" + fi.Name())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		recs = append(recs, results.Record{File: fi.Name(), Data: r})
+	}
+	return recs
+}
 
-			json := string(data)
-			json = strings.Replace(json, "  ", "    ", -1)
-			if strings.HasSuffix(json, "\n}\n") {
-				json = json[:len(json)-3] + "\n  }\n"
-			}
+// encodeDelimited returns the records as a table with a header row, one row
+// per configuration, and every metric as a column.
+func encodeDelimited(recs []results.Record, comma rune) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+	var header []string
+	for _, col := range results.Columns() {
+		header = append(header, col.Name)
+	}
+	w.Write(header)
+	for _, rec := range recs {
+		w.Write(rec.Row())
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
 
-			if count > 0 {
-				out += ","
+// encodeNDJSON returns the records as newline-delimited json, one flat object
+// per configuration.
+func encodeNDJSON(recs []results.Record) []byte {
+	cols := results.Columns()
+	var dst []byte
+	for _, rec := range recs {
+		dst = append(dst, '{')
+		for i, v := range rec.Row() {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			name, _ := json.Marshal(cols[i].Name)
+			dst = append(dst, name...)
+			dst = append(dst, ':')
+			if cols[i].Numeric && v == "" {
+				dst = append(dst, "null"...)
+			} else if cols[i].Numeric {
+				dst = append(dst, v...)
+			} else {
+				val, _ := json.Marshal(v)
+				dst = append(dst, val...)
 			}
-			out += "{\n" + `  "file": "` + fi.Name() + `",` + "\n" +
-				`  "data": `
-			out += json
-			out += `}`
-			count++
 		}
+		dst = append(dst, "}\n"...)
 	}
-	out += "\n]\n"
-	os.WriteFile(path+"/output.json", []byte(out), 0666)
+	return dst
 }
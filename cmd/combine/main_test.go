@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+// testRecords returns a run with perf, where one counter is missing, and a
+// run without perf from a host with a comma in its name.
+func testRecords() []results.Record {
+	a := results.Run{Info: results.Info{Cache: "valkey", Version: "8.1.1",
+		Threads: 4, Pipeline: 1, Kind: "median"}}
+	a.Gets.Opsec = 1500.5
+	a.Perf.Cycles = 1000
+	a.Perf.Branches = math.NaN()
+	b := results.Run{Info: results.Info{Cache: "redis", Threads: 1,
+		Pipeline: 8, Host: "arm,64", Kind: "best"}}
+	b.Gets.Opsec = 900
+	return []results.Record{
+		{File: "a.json", Source: "x86", Data: a},
+		{File: "b.json", Data: b},
+	}
+}
+
+func TestEncodeDelimited(t *testing.T) {
+	want := []map[string]string{
+		{"file": "a.json", "source": "x86", "cache": "valkey",
+			"threads": "4", "perf": "yes", "gets.opsec": "1500.500",
+			"perf.cycles": "1000", "perf.branches": ""},
+		{"file": "b.json", "source": "", "host": "arm,64",
+			"pipeline": "8", "perf": "no", "gets.opsec": "900.000",
+			"perf.cycles": ""},
+	}
+	var names []string
+	for _, col := range results.Columns() {
+		names = append(names, col.Name)
+	}
+	for _, comma := range []rune{',', '\t'} {
+		r := csv.NewReader(bytes.NewReader(
+			encodeDelimited(testRecords(), comma)))
+		r.Comma = comma
+		rows, err := r.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 3 || !reflect.DeepEqual(rows[0], names) {
+			t.Fatalf("%q: encoded as %q", comma, rows)
+		}
+		for j, w := range want {
+			for name, v := range w {
+				got := rows[j+1][slices.Index(names, name)]
+				if got != v {
+					t.Errorf("%q: %s is '%s', want '%s'",
+						comma, name, got, v)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeNDJSON(t *testing.T) {
+	want := []map[string]any{
+		{"file": "a.json", "source": "x86", "threads": 4.0,
+			"perf": "yes", "gets.opsec": 1500.5,
+			"perf.cycles": 1000.0, "perf.branches": nil},
+		{"file": "b.json", "source": "", "host": "arm,64",
+			"pipeline": 8.0, "gets.opsec": 900.0,
+			"perf.cycles": nil},
+	}
+	lines := bytes.Split(encodeNDJSON(testRecords()), []byte("\n"))
+	if len(lines) != 3 || len(lines[2]) != 0 {
+		t.Fatalf("%d lines, want 2 and a final newline", len(lines)-1)
+	}
+	for i, w := range want {
+		var obj map[string]any
+		if err := json.Unmarshal(lines[i], &obj); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if len(obj) != len(results.Columns()) {
+			t.Errorf("line %d has %d values, want %d", i+1,
+				len(obj), len(results.Columns()))
+		}
+		for name, v := range w {
+			if got, ok := obj[name]; !ok || got != v {
+				t.Errorf("line %d %s is %v, want %v", i+1, name,
+					got, v)
+			}
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	// dir returns a results directory with a median run of valkey at one
+	// thread, from the host and with the opsec.
+	dir := func(host string, opsec float64) string {
+		path := t.TempDir()
+		r := results.Run{Info: results.Info{Cache: "valkey", Threads: 1,
+			Pipeline: 1, Connections: 1, Operations: 1, Host: host,
+			Kind: "median"}}
+		r.Gets.Opsec = opsec
+		if err := os.Mkdir(path+"/runs", 0777); err != nil {
+			t.Fatal(err)
+		}
+		file := path + "/runs/" + r.Key().File("median")
+		if err := results.WriteRun(file, r); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// Records conflict when they have the same file and origin, but not
+	// the same data.
+	tests := []struct {
+		name      string
+		paths     []string
+		labels    []string
+		keep      string
+		opsec     []float64
+		conflicts int
+	}{
+		{"same data", []string{dir("a", 1), dir("a", 1)},
+			[]string{"", ""}, "error", []float64{1}, 0},
+		{"first", []string{dir("a", 1), dir("a", 2)},
+			[]string{"", ""}, "first", []float64{1}, 1},
+		{"last", []string{dir("a", 1), dir("a", 2)},
+			[]string{"", ""}, "last", []float64{2}, 1},
+		{"labels", []string{dir("a", 1), dir("a", 2)},
+			[]string{"x86", "arm"}, "error", []float64{1, 2}, 0},
+		{"same label", []string{dir("a", 1), dir("b", 2)},
+			[]string{"x86", "x86"}, "last", []float64{2}, 1},
+		{"hosts", []string{dir("a", 1), dir("b", 2)},
+			[]string{"", ""}, "error", []float64{1, 2}, 0},
+		{"no hosts", []string{dir("", 1), dir("", 2)},
+			[]string{"", ""}, "error", []float64{1, 2}, 0},
+	}
+	for _, tt := range tests {
+		conflicts = tt.keep
+		recs, n := merge(tt.paths, tt.labels)
+		var opsec []float64
+		for _, rec := range recs {
+			opsec = append(opsec, rec.Data.Gets.Opsec)
+		}
+		if n != tt.conflicts || !reflect.DeepEqual(opsec, tt.opsec) {
+			t.Errorf("%s: %d conflicts and %v, want %d and %v",
+				tt.name, n, opsec, tt.conflicts, tt.opsec)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/cache-benchmarks/chart"
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+var adir string
+var bdir string
+var out string
+var kind string = "median"
+var metrics string = "gets.opsec,sets.opsec,gets.latency.p99_00," +
+	"sets.latency.p99_00,gets.latency.p99_90,sets.latency.p99_90"
+var include string
+var exclude string
+var alpha float64 = 0.05
+var listAll bool
+var format string = "png"
+var configPath string = "config.jsonc"
+
+func main() {
+	flag.StringVar(&adir, "a", adir, "Results directory before the "+
+		"change (A)")
+	flag.StringVar(&bdir, "b", bdir, "Results directory after the "+
+		"change (B)")
+	flag.StringVar(&out, "out", out, "directory to write compare.md and the "+
+		"charts to (default is compare in the B directory)")
+	flag.StringVar(&kind, "kind", kind, "median,average,best,worst")
+	flag.StringVar(&metrics, "metrics", metrics, "Metrics to compare, such "+
+		"as gets.opsec, sets.latency.p99_90 or perf.cycles")
+	flag.StringVar(&include, "include", include, "Only compare the runs "+
+		"that match, such as 'cache=valkey,pipeline=1|10'")
+	flag.StringVar(&exclude, "exclude", exclude, "Don't compare the runs "+
+		"that match, such as 'cache=garnet'")
+	flag.Float64Var(&alpha, "alpha", alpha, "Significance level of the "+
+		"changes")
+	flag.BoolVar(&listAll, "all", listAll, "List every change, not only the "+
+		"significant ones")
+	flag.StringVar(&format, "format", format, "Charts: png,svg")
+	flag.StringVar(&configPath, "config", configPath, "config path, for the "+
+		"colors and names of the caches")
+	flag.Parse()
+
+	if adir == "" || bdir == "" {
+		fmt.Printf("both --a and --b are needed\n")
+		os.Exit(1)
+	}
+	if out == "" {
+		out = filepath.Join(bdir, "compare")
+	}
+	if alpha <= 0 || alpha >= 1 {
+		fmt.Printf("invalid flag --alpha='%v'\n", alpha)
+		os.Exit(1)
+	}
+	switch format {
+	case "png", "svg":
+	default:
+		fmt.Printf("invalid flag --format='%s'\n", format)
+		os.Exit(1)
+	}
+	known := map[string]bool{}
+	for _, name := range results.Metrics() {
+		known[name] = true
+	}
+	names := strings.Split(metrics, ",")
+	for _, name := range names {
+		if !known[name] {
+			fmt.Printf("invalid flag --metrics='%s'\n", metrics)
+			os.Exit(1)
+		}
+	}
+	var inc, exc results.Filter
+	var err error
+	if include != "" {
+		if inc, err = results.ParseFilter(include); err != nil {
+			fmt.Printf("invalid flag --include='%s'\n", include)
+			os.Exit(1)
+		}
+	}
+	if exclude != "" {
+		if exc, err = results.ParseFilter(exclude); err != nil {
+			fmt.Printf("invalid flag --exclude='%s'\n", exclude)
+			os.Exit(1)
+		}
+	}
+	keep := func(info results.Info) bool {
+		return inc.Match(info) && (exc == nil || !exc.Match(info))
+	}
+
+	a, runsA := readResults(adir, keep)
+	b, runsB := readResults(bdir, keep)
+	deltas := results.Compare(a, b, runsA, runsB, kind, names)
+	if len(deltas) == 0 {
+		fmt.Fprintf(os.Stderr, "no configurations are in both %s and %s\n",
+			adir, bdir)
+		os.Exit(1)
+	}
+
+	st, err := chart.ReadStyles(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(out, 0777); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	files := drawCharts(deltas, names, st)
+
+	writeReport(os.Stdout, a, b, deltas, false)
+	f, err := os.Create(filepath.Join(out, "compare.md"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	writeReport(f, a, b, deltas, true)
+	fmt.Fprintf(f, "\n## Charts\n\n")
+	fmt.Fprintf(f, "The change of B from A at each point. Significant "+
+		"changes are marked as better or worse.\n\n")
+	for _, file := range files {
+		fmt.Fprintf(f, "![%s](%s)\n", file, file)
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// readResults reads the aggregate records and the numbered runs of the
+// results directory that are kept by the filter. A directory without runs is
+// compared without p-values.
+func readResults(dir string, keep func(results.Info) bool,
+) ([]results.Record, map[results.Key][]results.Run) {
+	all, err := results.ReadOutput(filepath.Join(dir, "output.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	var recs []results.Record
+	for _, rec := range all {
+		if keep(rec.Data.Info) {
+			recs = append(recs, rec)
+		}
+	}
+	runs, err := results.ReadRunDir(filepath.Join(dir, "runs"))
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	return recs, runs
+}
+
+// versions returns the version of each cache in the records.
+func versions(recs []results.Record) map[string]string {
+	m := map[string]string{}
+	for _, rec := range recs {
+		m[rec.Data.Info.Cache] = rec.Data.Info.Version
+	}
+	return m
+}
+
+// writeReport writes the versions, a summary and the table of changes, as
+// text or as markdown.
+func writeReport(w io.Writer, a, b []results.Record,
+	deltas []results.Delta, markdown bool,
+) {
+	heading := func(text string) {
+		if markdown {
+			fmt.Fprintf(w, "## %s\n\n", text)
+		} else {
+			fmt.Fprintf(w, "%s\n\n", strings.ToUpper(text))
+		}
+	}
+	if markdown {
+		fmt.Fprintf(w, "# Comparison\n\n")
+	}
+	fmt.Fprintf(w, "A is %s and B is %s, using the %s runs. Changes with "+
+		"q < %v are significant, where q is the p-value adjusted for "+
+		"the number of comparisons.\n\n", adir, bdir, kind, alpha)
+
+	heading("Versions")
+	va, vb := versions(a), versions(b)
+	seen := map[string]bool{}
+	var rows [][]string
+	for _, d := range deltas {
+		cache := d.Key.Cache
+		if !seen[cache] {
+			seen[cache] = true
+			rows = append(rows, []string{cache, va[cache], vb[cache]})
+		}
+	}
+	writeTable(w, []string{"Cache", "A", "B"}, rows, markdown)
+
+	// The regressions are listed first.
+	var regressions, improvements int
+	var worse [][]string
+	rows = nil
+	for _, d := range deltas {
+		result := ""
+		switch {
+		case !d.Significant(alpha):
+		case d.Better():
+			result = "improvement"
+			improvements++
+		case d.Worse():
+			result = "REGRESSION"
+			regressions++
+		}
+		if result == "" && !listAll {
+			continue
+		}
+		row := []string{
+			d.Metric, d.Key.Cache, strconv.Itoa(d.Key.Pipeline),
+			strconv.Itoa(d.Key.Threads), chart.Label(d.A), chart.Label(d.B),
+			percent(d.Change), pvalue(d.P), pvalue(d.Q), result,
+		}
+		if result == "REGRESSION" {
+			worse = append(worse, row)
+		} else {
+			rows = append(rows, row)
+		}
+	}
+	rows = append(worse, rows...)
+	heading("Changes")
+	fmt.Fprintf(w, "%d regressions and %d improvements in %d "+
+		"comparisons.\n\n", regressions, improvements, len(deltas))
+	if len(rows) > 0 {
+		writeTable(w, []string{"Metric", "Cache", "Pipeline", "Threads", "A",
+			"B", "Change", "p", "q", "Result"}, rows, markdown)
+	}
+}
+
+// percent returns the change as a signed percent, such as "+8.1%".
+func percent(change float64) string {
+	if math.IsNaN(change) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", change*100)
+}
+
+// pvalue returns the p-value in a short form.
+func pvalue(p float64) string {
+	switch {
+	case math.IsNaN(p):
+		return "n/a"
+	case p < 0.001:
+		return "<0.001"
+	}
+	return fmt.Sprintf("%.3f", p)
+}
+
+// writeTable writes the rows with a header, as a markdown table or as text
+// in aligned columns.
+func writeTable(w io.Writer, header []string, rows [][]string,
+	markdown bool,
+) {
+	if markdown {
+		fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+		for _, row := range rows {
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		}
+		fmt.Fprintf(w, "\n")
+		return
+	}
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		var line string
+		for i, cell := range row {
+			line += fmt.Sprintf("%-*s  ", widths[i], cell)
+		}
+		fmt.Fprintf(w, "%s\n", strings.TrimRight(line, " "))
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// drawCharts draws a bar chart of the changes of each metric and pipeline,
+// with a bar for each cache at each threads. The significant changes are
+// marked as better or worse. It returns the names of the chart files.
+func drawCharts(deltas []results.Delta, names []string,
+	st *chart.Styles,
+) []string {
+	var caches []string
+	var pipelines []int
+	var threadz []int
+	cm := map[string]bool{}
+	pm := map[int]bool{}
+	tm := map[int]bool{}
+	for _, d := range deltas {
+		k := d.Key
+		if !cm[k.Cache] {
+			cm[k.Cache] = true
+			caches = append(caches, k.Cache)
+		}
+		if !pm[k.Pipeline] {
+			pm[k.Pipeline] = true
+			pipelines = append(pipelines, k.Pipeline)
+		}
+		if !tm[k.Threads] {
+			tm[k.Threads] = true
+			threadz = append(threadz, k.Threads)
+		}
+	}
+	sort.Ints(pipelines)
+	sort.Ints(threadz)
+	styles := st.Assign(caches, caches)
+
+	var files []string
+	for _, metric := range names {
+		for _, pipeline := range pipelines {
+			// change of each cache by threads
+			changes := map[string]map[int]results.Delta{}
+			for _, d := range deltas {
+				if d.Metric == metric && d.Key.Pipeline == pipeline {
+					if changes[d.Key.Cache] == nil {
+						changes[d.Key.Cache] = map[int]results.Delta{}
+					}
+					changes[d.Key.Cache][d.Key.Threads] = d
+				}
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			bar := &chart.Bar{
+				Title:  fmt.Sprintf("%s - Pipeline %d", metric, pipeline),
+				XTitle: "Threads",
+				YTitle: "Change of B from A (%)",
+			}
+			for _, threads := range threadz {
+				bar.X = append(bar.X, fmt.Sprint(threads))
+			}
+			for i, cache := range caches {
+				if changes[cache] == nil {
+					continue
+				}
+				color, err := chart.ParseColor(styles[i].Color)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: color of %s: %s\n",
+						configPath, cache, err)
+					os.Exit(1)
+				}
+				s := chart.Series{
+					Name: styles[i].Name, Color: color, Hatch: styles[i].Hatch,
+				}
+				for _, threads := range threadz {
+					d, ok := changes[cache][threads]
+					v, note := math.NaN(), ""
+					if ok {
+						v = d.Change * 100
+						if d.Significant(alpha) && d.Better() {
+							note = "better"
+						} else if d.Significant(alpha) && d.Worse() {
+							note = "worse"
+						}
+					}
+					s.Values = append(s.Values, v)
+					s.Notes = append(s.Notes, note)
+				}
+				bar.Series = append(bar.Series, s)
+			}
+			name := fmt.Sprintf("compare_%s-pipeline_%d.%s",
+				strings.ReplaceAll(metric, ".", "_"), pipeline, format)
+			writeChart(bar, filepath.Join(out, name))
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// writeChart writes the chart to the file, as a PNG or an SVG.
+func writeChart(ch chart.Chart, filename string) {
+	var data []byte
+	if format == "svg" {
+		data = chart.SVG(ch)
+	} else {
+		var err error
+		data, err = chart.PNG(ch, 1.5)
+		if err != nil {
+			panic(err)
+		}
+	}
+	err := os.WriteFile(filename, data, 0666)
+	if err != nil {
+		panic(err)
+	}
+}
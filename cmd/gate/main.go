@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/cache-benchmarks/chart"
+	"github.com/tidwall/cache-benchmarks/results"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/jsonc"
+)
+
+var dir string = "results"
+var baseline string
+var thresholds string = "thresholds.jsonc"
+var kind string = "median"
+var alpha float64 = 0
+
+// rule limits how far a metric of the runs that match it may move from the
+// baseline, in percent. A limit is NaN when there is none.
+type rule struct {
+	metric  string
+	where   results.Filter
+	maxDrop float64
+	maxRise float64
+}
+
+// String returns the rule in words, such as "gets.opsec (pipeline=1) may
+// not drop more than 5%".
+func (r rule) String() string {
+	s := r.metric
+	if len(r.where) > 0 {
+		s += " (" + r.where.String() + ")"
+	}
+	var limits []string
+	if !math.IsNaN(r.maxDrop) {
+		limits = append(limits,
+			fmt.Sprintf("drop more than %v%%", r.maxDrop))
+	}
+	if !math.IsNaN(r.maxRise) {
+		limits = append(limits,
+			fmt.Sprintf("rise more than %v%%", r.maxRise))
+	}
+	return s + " may not " + strings.Join(limits, " or ")
+}
+
+// violated returns true when the change breaks the rule. With --alpha, the
+// change must also be significant, after the p-value is adjusted for the
+// number of changes, unless there are no runs to test it with.
+func (r rule) violated(d results.Delta) bool {
+	change := d.Change * 100
+	if math.IsNaN(change) {
+		return false
+	}
+	if (math.IsNaN(r.maxDrop) || change >= -r.maxDrop) &&
+		(math.IsNaN(r.maxRise) || change <= r.maxRise) {
+		return false
+	}
+	return alpha == 0 || math.IsNaN(d.Q) || d.Significant(alpha)
+}
+
+func main() {
+	flag.StringVar(&dir, "dir", dir, "Results directory to check")
+	flag.StringVar(&baseline, "baseline", baseline, "Results directory "+
+		"that is checked against")
+	flag.StringVar(&thresholds, "thresholds", thresholds, "File of the "+
+		"rules that the results are checked with")
+	flag.StringVar(&kind, "kind", kind, "median,average,best,worst")
+	flag.Float64Var(&alpha, "alpha", alpha, "Only count changes that are "+
+		"significant at this level, when there are runs (0 counts all)")
+	flag.Parse()
+
+	if baseline == "" {
+		fmt.Printf("--baseline is needed\n")
+		os.Exit(1)
+	}
+	if alpha < 0 || alpha >= 1 {
+		fmt.Printf("invalid flag --alpha='%v'\n", alpha)
+		os.Exit(1)
+	}
+	rules, err := readRules(thresholds)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", thresholds, err)
+		os.Exit(1)
+	}
+	var metrics []string
+	seen := map[string]bool{}
+	for _, r := range rules {
+		if !seen[r.metric] {
+			seen[r.metric] = true
+			metrics = append(metrics, r.metric)
+		}
+	}
+	a, runsA := readResults(baseline)
+	b, runsB := readResults(dir)
+	deltas := results.Compare(a, b, runsA, runsB, kind, metrics)
+
+	fmt.Printf("Checking %s against %s, using the %s runs.\n\n", dir,
+		baseline, kind)
+	var width int
+	for _, r := range rules {
+		width = max(width, len(r.String()))
+	}
+	var failed int
+	var rows [][]string
+	for _, r := range rules {
+		var checked, violations int
+		for _, d := range deltas {
+			if d.Metric != r.metric || !r.where.Match(d.Info) {
+				continue
+			}
+			checked++
+			if r.violated(d) {
+				violations++
+				rows = append(rows, row(d))
+			}
+		}
+		var status string
+		switch {
+		case checked == 0:
+			// A rule that checks nothing is most likely a mistake,
+			// such as a typo in its filter, and is not allowed to
+			// pass.
+			status = "FAIL: no runs in both"
+			failed++
+		case violations > 0:
+			status = fmt.Sprintf("FAIL: %d of %d", violations,
+				checked)
+			failed++
+		default:
+			status = fmt.Sprintf("ok: %d checked", checked)
+		}
+		fmt.Printf("%-*s  %s\n", width, r, status)
+	}
+	if len(rows) > 0 {
+		fmt.Printf("\nVIOLATIONS\n\n")
+		writeTable([]string{"Metric", "Cache", "Pipeline", "Threads",
+			"Baseline", "New", "Change", "p", "q"}, rows)
+	}
+	if failed > 0 {
+		fmt.Printf("\nFAIL: %d of %d rules\n", failed, len(rules))
+		os.Exit(2)
+	}
+	fmt.Printf("\nPASS\n")
+}
+
+// readRules reads the rules of the thresholds file.
+func readRules(path string) ([]rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, name := range results.Metrics() {
+		known[name] = true
+	}
+	limit := func(v gjson.Result) float64 {
+		if !v.Exists() {
+			return math.NaN()
+		}
+		return v.Float()
+	}
+	var rules []rule
+	config := jsonc.ToJSONInPlace(data)
+	for i, v := range gjson.GetBytes(config, "rules").Array() {
+		r := rule{
+			metric:  v.Get("metric").String(),
+			maxDrop: limit(v.Get("max_drop")),
+			maxRise: limit(v.Get("max_rise")),
+		}
+		if !known[r.metric] {
+			return nil, fmt.Errorf("rule %d: unknown metric '%s'",
+				i+1, r.metric)
+		}
+		if math.IsNaN(r.maxDrop) && math.IsNaN(r.maxRise) {
+			return nil, fmt.Errorf("rule %d: needs a max_drop or "+
+				"max_rise", i+1)
+		}
+		if where := v.Get("where").String(); where != "" {
+			r.where, err = results.ParseFilter(where)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %s", i+1, err)
+			}
+		}
+		rules = append(rules, r)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no rules")
+	}
+	return rules, nil
+}
+
+// readResults reads the aggregate records and the numbered runs of the
+// results directory. A directory without runs is checked without p-values.
+func readResults(dir string) ([]results.Record,
+	map[results.Key][]results.Run,
+) {
+	recs, err := results.ReadOutput(filepath.Join(dir, "output.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	var runs map[results.Key][]results.Run
+	if alpha > 0 {
+		runs, err = results.ReadRunDir(filepath.Join(dir, "runs"))
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+	return recs, runs
+}
+
+// row returns the cells of the violation in the report.
+func row(d results.Delta) []string {
+	return []string{d.Metric, d.Key.Cache, strconv.Itoa(d.Key.Pipeline),
+		strconv.Itoa(d.Key.Threads), chart.Label(d.A), chart.Label(d.B),
+		fmt.Sprintf("%+.1f%%", d.Change*100), pvalue(d.P), pvalue(d.Q),
+	}
+}
+
+// pvalue returns the p-value in a short form.
+func pvalue(p float64) string {
+	switch {
+	case math.IsNaN(p):
+		return "n/a"
+	case p < 0.001:
+		return "<0.001"
+	}
+	return fmt.Sprintf("%.3f", p)
+}
+
+// writeTable writes the rows with a header in aligned columns.
+func writeTable(header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		var line string
+		for i, cell := range row {
+			line += fmt.Sprintf("%-*s  ", widths[i], cell)
+		}
+		fmt.Printf("%s\n", strings.TrimRight(line, " "))
+	}
+}
@@ -1,14 +1,15 @@
 module github.com/tidwall/cache-benchmarks
 
-go 1.23
+go 1.23.0
 
 require (
 	github.com/tidwall/gjson v1.18.0
 	github.com/tidwall/jsonc v0.3.2
-	github.com/tidwall/sjson v1.2.5
+	golang.org/x/image v0.30.0
 )
 
 require (
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
 )
@@ -3,361 +3,1647 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/tidwall/gjson"
+	"github.com/tidwall/cache-benchmarks/chart"
+	"github.com/tidwall/cache-benchmarks/results"
 )
 
-var json string
-var clients int
-var coperations int
-var caches []string   // caches
-var threadz []int     // threads
-var versions []string // versions for caches
 var dir string = "results"
-var kind string = "median"
-var which string = "get"
-var pipeline int = 1
-var ppp string = "99"
-var bench string = "throughput"
 var force bool = false
-var scale string = "logarithmic"
-var scase string = ""
+var jobs int = 1
+var specs string = ""
+var configPath string = "config.jsonc"
+var runs map[results.Key][]results.Run // numbered runs, for --errors and box
+var runsMu sync.Mutex                  // guards runs
+
+// graph is a single graph, with its options and the records that it's drawn
+// from. Many graphs may be drawn at once, each from its own graph.
+type graph struct {
+	kind      string
+	which     string
+	pipeline  int
+	nthreads  int
+	ppp       string
+	bench     string
+	scale     string
+	scase     string
+	renderer  string
+	format    string
+	kindchart string
+	errors    string
+	cache     string
+	baseline  string
+	relative  string
+	xfield    string
+	includes  list // --include flags
+	excludes  list // --exclude flags
+	annotates list // --annotate flags
+	fixes     list // --fix flags
+
+	recs        []results.Record
+	clients     int
+	coperations int
+	origins     bool     // records are from more than one host or sweep
+	caches      []string // caches, or series when there are many origins
+	threadz     []int    // threads
+	versions    []string // versions for caches
+	xvalues     []string // values of the x field, in order
+	filters     []filter // parsed from the include and exclude
+	notes       []note   // parsed from the annotate flags
+
+	infos  map[string]results.Info // info of each series, for the notes
+	styles []chart.SeriesStyle     // style of each series, from the config
+	table  []byte                  // drawn table, for the text formats
+}
+
+// list is a flag that may be used more than once.
+type list []string
+
+func (l *list) String() string { return strings.Join(*l, " ") }
+func (l *list) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// filter keeps or drops the runs that match it.
+type filter struct {
+	match   results.Filter
+	exclude bool
+}
+
+// note is an annotation of the points that match it.
+type note struct {
+	match results.Filter
+	text  string
+}
 
 const fontfamily string = "Futura"
 
-var colors = []string{
-	"#ff7f0e", "#d62728", "#1f77b4", "#e64098", "#8c564b", "#2ca02c",
+// newGraph returns a graph with the default options.
+func newGraph() *graph {
+	return &graph{
+		kind:      "median",
+		which:     "get",
+		pipeline:  1,
+		ppp:       "99",
+		bench:     "throughput",
+		scale:     "logarithmic",
+		renderer:  "go",
+		format:    "png",
+		kindchart: "bar",
+		relative:  "ratio",
+		xfield:    "threads",
+	}
+}
+
+// clone returns a copy of the options of the graph.
+func (g *graph) clone() *graph {
+	return &graph{
+		kind:      g.kind,
+		which:     g.which,
+		pipeline:  g.pipeline,
+		nthreads:  g.nthreads,
+		ppp:       g.ppp,
+		bench:     g.bench,
+		scale:     g.scale,
+		scase:     g.scase,
+		renderer:  g.renderer,
+		format:    g.format,
+		kindchart: g.kindchart,
+		errors:    g.errors,
+		cache:     g.cache,
+		baseline:  g.baseline,
+		relative:  g.relative,
+		xfield:    g.xfield,
+		includes:  slices.Clone(g.includes),
+		excludes:  slices.Clone(g.excludes),
+		annotates: slices.Clone(g.annotates),
+		fixes:     slices.Clone(g.fixes),
+	}
+}
+
+// flags adds the options of the graph to the flag set.
+func (g *graph) flags(fs *flag.FlagSet) {
+	fs.IntVar(&g.pipeline, "pipeline", g.pipeline, "1,10,25,50")
+	fs.StringVar(&g.ppp, "percentile", g.ppp,
+		"99th: avg,min,max,50,90,99,999,9999")
+	fs.StringVar(&g.which, "which", g.which, "set,get")
+	fs.StringVar(&g.bench, "bench", g.bench, "throughput,latency,cpucycles,"+
+		"spectrum,sizes,bandwidth,instructions,ipc,branchmisses,"+
+		"pagefaults,opspercpu,mbpercpu,systime")
+	fs.IntVar(&g.nthreads, "threads", g.nthreads, "spectrum, sizes, or --x "+
+		"other than threads: threads (default is the most threads)")
+	fs.StringVar(&g.xfield, "x", g.xfield, "x-axis: threads,pipeline,"+
+		"sizerange,connections,bench_threads,operations,version")
+	fs.Var(&g.fixes, "fix", "Pin a field to a value, such as "+
+		"'sizerange=1-64' (repeatable)")
+	fs.StringVar(&g.kind, "kind", g.kind, "median,average,best,worst")
+	fs.StringVar(&g.scale, "scale", g.scale, "logarithmic,linear")
+	fs.StringVar(&g.scase, "scase", g.scase, "special case: 1=remove garnet "+
+		"(thread 1), same as --exclude='cache=garnet,threads=1'")
+	fs.Var(&g.includes, "include", "Only graph the runs that match, such as "+
+		"'cache=redis|valkey,threads=1|16' (repeatable)")
+	fs.Var(&g.excludes, "exclude", "Don't graph the runs that match, such as "+
+		"'cache=garnet,threads=1' (repeatable)")
+	fs.Var(&g.annotates, "annotate", "Annotate the points that match, such "+
+		"as 'cache=garnet,threads=1:omitted: latency off-scale' (repeatable)")
+	fs.StringVar(&g.renderer, "renderer", g.renderer, "go,python")
+	fs.StringVar(&g.format, "format", g.format, "png,svg,text,markdown "+
+		"(text and markdown print tables instead of writing files)")
+	fs.StringVar(&g.kindchart, "chart", g.kindchart, "bar,line,box,heatmap,"+
+		"winner (heatmaps are threads by pipeline)")
+	fs.StringVar(&g.cache, "cache", g.cache, "heatmap: cache to draw")
+	fs.StringVar(&g.baseline, "baseline", g.baseline, "Draw each cache "+
+		"relative to this cache")
+	fs.StringVar(&g.relative, "relative", g.relative, "baseline: "+
+		"ratio,percent (percent needs --scale=linear)")
+	fs.StringVar(&g.errors, "errors", g.errors, "Error bars from the runs: "+
+		"minmax,iqr,ci (95% confidence interval)")
+	fs.BoolFunc("minmax", "Same as --errors=minmax", func(string) error {
+		g.errors = "minmax"
+		return nil
+	})
 }
 
 func main() {
 
 	// fmt.Printf("%s\n", os.Args)
-	flag.IntVar(&pipeline, "pipeline", pipeline, "1,10,25,50")
-	flag.StringVar(&ppp, "percentile", ppp, "99th: avg,min,max,50,90,99,999,9999")
-	flag.StringVar(&which, "which", which, "set,get")
-	flag.StringVar(&bench, "bench", bench, "throughput,latency,cpucycles")
-	flag.StringVar(&kind, "kind", kind, "median,average,best,worst")
+	g := newGraph()
+	g.flags(flag.CommandLine)
 	flag.StringVar(&dir, "dir", dir, "Results directory")
+	flag.StringVar(&configPath, "config", configPath, "config path, for the "+
+		"colors, hatching and names of the caches")
 	flag.BoolVar(&force, "force", force, "Force write (overwrite)")
-	flag.StringVar(&scale, "scale", scale, "logarithmic,linear")
-	flag.StringVar(&scase, "scase", scase, "special case: 1=remove garnet (thread 1)")
+	flag.StringVar(&specs, "specs", specs, "File of graph specs, one per "+
+		"line, to draw along with the spec arguments")
+	flag.IntVar(&jobs, "jobs", jobs, "number of graphs to draw in parallel")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: graph [flags] "+
+			"[spec ...]\n\nEach spec is the flags of a graph without the "+
+			"dashes, such as\n'bench=latency pipeline=* percentile=9*', or "+
+			"'all' for the graphs of\nbench-all.sh. The flags below are the "+
+			"defaults of every spec. Without\nspecs, the single graph of "+
+			"the flags is drawn.\n\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	data, err := os.ReadFile(dir + "/output.json")
+	all, err := results.ReadOutput(dir + "/output.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if len(all) == 0 {
+		fmt.Fprintf(os.Stderr, "%s/output.json: no records\n", dir)
+		os.Exit(1)
+	}
+	st, err := chart.ReadStyles(configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
-	json = string(data)
 
-	switch bench {
-	case "throughput", "cpucycles", "latency":
+	lines := flag.Args()
+	if specs != "" {
+		data, err := os.ReadFile(specs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		g.setup(all, st)
+		filename, draw := g.describe()
+		if !g.tables() && !force && exists(filename) {
+			return
+		}
+		draw()
+		os.Stdout.Write(g.table)
+		return
+	}
+
+	// Every graph is set up before any are drawn, so that a bad spec stops
+	// the program early. Specs that expand to the same file are drawn once.
+	var todo []*graph
+	var draws []func()
+	seen := map[string]bool{}
+	for _, line := range lines {
+		for _, args := range expandSpec(line, all) {
+			sg := g.clone()
+			fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			sg.flags(fs)
+			if err := fs.Parse(args); err != nil {
+				fmt.Printf("invalid spec '%s': %s\n", line, err)
+				os.Exit(1)
+			}
+			if fs.NArg() > 0 {
+				fmt.Printf("invalid spec '%s'\n", line)
+				os.Exit(1)
+			}
+			sg.setup(all, st)
+			filename, draw := sg.describe()
+			if seen[filename] ||
+				(!sg.tables() && !force && exists(filename)) {
+				continue
+			}
+			seen[filename] = true
+			todo = append(todo, sg)
+			draws = append(draws, draw)
+		}
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	var wg sync.WaitGroup
+	ch := make(chan func())
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for draw := range ch {
+				draw()
+			}
+		}()
+	}
+	for _, draw := range draws {
+		ch <- draw
+	}
+	close(ch)
+	wg.Wait()
+
+	// Tables are printed in the order of the specs, once all are drawn.
+	for _, sg := range todo {
+		os.Stdout.Write(sg.table)
+	}
+}
+
+// setup checks the options of the graph and picks the records that it's
+// drawn from.
+func (g *graph) setup(all []results.Record, st *chart.Styles) {
+	g.parseFilters()
+	for _, rec := range all {
+		if g.keep(rec.Data.Info) {
+			g.recs = append(g.recs, rec)
+		}
+	}
+	if len(g.recs) == 0 {
+		fmt.Fprintf(os.Stderr, "%s/output.json: no records match the "+
+			"filters\n", dir)
+		os.Exit(1)
+	}
+
+	switch g.bench {
+	case "throughput", "cpucycles", "latency", "spectrum", "sizes",
+		"bandwidth", "instructions", "ipc", "branchmisses", "pagefaults",
+		"opspercpu", "mbpercpu", "systime":
 	default:
-		fmt.Printf("invalid flag --bench='%s'\n", bench)
+		fmt.Printf("invalid flag --bench='%s'\n", g.bench)
 		os.Exit(1)
 	}
-	switch scale {
+	switch g.scale {
 	case "logarithmic", "linear":
 	default:
-		fmt.Printf("invalid flag --scale='%s'\n", scale)
+		fmt.Printf("invalid flag --scale='%s'\n", g.scale)
+		os.Exit(1)
+	}
+	switch g.renderer {
+	case "go", "python":
+	default:
+		fmt.Printf("invalid flag --renderer='%s'\n", g.renderer)
+		os.Exit(1)
+	}
+	switch g.format {
+	case "png":
+	case "svg", "text", "markdown":
+		if g.renderer == "python" {
+			fmt.Printf("the python renderer only supports --format=png\n")
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("invalid flag --format='%s'\n", g.format)
+		os.Exit(1)
+	}
+	switch g.kindchart {
+	case "bar":
+	case "line", "box", "heatmap", "winner":
+		if g.renderer == "python" {
+			fmt.Printf("the python renderer only supports --chart=bar\n")
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("invalid flag --chart='%s'\n", g.kindchart)
+		os.Exit(1)
+	}
+	if g.bench == "spectrum" && (g.renderer == "python" || g.kindchart != "bar" ||
+		g.errors != "" || g.baseline != "") {
+		fmt.Printf("--bench=spectrum only supports the go renderer, " +
+			"without --chart, --errors or --baseline\n")
+		os.Exit(1)
+	}
+	if g.bench == "sizes" && (g.renderer == "python" ||
+		(g.kindchart != "bar" && g.kindchart != "line") ||
+		g.errors != "" || g.baseline != "") {
+		fmt.Printf("--bench=sizes only supports the go renderer, with " +
+			"--chart=bar or line, without --errors or --baseline\n")
+		os.Exit(1)
+	}
+	switch g.relative {
+	case "ratio":
+	case "percent":
+		if g.baseline != "" && g.scale != "linear" {
+			fmt.Printf("--relative=percent needs --scale=linear\n")
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("invalid flag --relative='%s'\n", g.relative)
+		os.Exit(1)
+	}
+	switch g.xfield {
+	case "threads":
+	case "pipeline", "sizerange", "connections", "bench_threads",
+		"operations", "version":
+		if g.heatmap() || g.bench == "spectrum" || g.bench == "sizes" {
+			fmt.Printf("--x can't be used with heatmaps, " +
+				"spectrums or sizes\n")
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("invalid flag --x='%s'\n", g.xfield)
+		os.Exit(1)
+	}
+	if len(g.notes) > 0 && (g.renderer == "python" || g.heatmap() ||
+		g.bench == "spectrum" || g.bench == "sizes") {
+		fmt.Printf("--annotate only supports the go renderer, " +
+			"without heatmaps, spectrums or sizes\n")
+		os.Exit(1)
+	}
+	if g.baseline != "" && (g.renderer == "python" || g.heatmap()) {
+		fmt.Printf("--baseline only supports the go renderer, " +
+			"without heatmaps\n")
+		os.Exit(1)
+	}
+	switch g.errors {
+	case "":
+	case "minmax", "iqr", "ci":
+		if g.renderer == "python" {
+			fmt.Printf("the python renderer does not support --errors\n")
+			os.Exit(1)
+		}
+		if g.kindchart == "box" || g.heatmap() {
+			fmt.Printf("--errors can't be used with --chart=%s\n", g.kindchart)
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("invalid flag --errors='%s'\n", g.errors)
 		os.Exit(1)
 	}
 
 	// Get the name of all cache programs and the versions
+	for _, rec := range g.recs {
+		if rec.Origin() != g.recs[0].Origin() {
+			g.origins = true
+			break
+		}
+	}
 	cm := map[string]bool{}
-	gjson.Get(json, "#.data.info.cache").ForEach(
-		func(_, name gjson.Result) bool {
-			if !cm[name.String()] {
-				caches = append(caches, name.String())
-				cm[name.String()] = true
-				versions = append(versions, gjson.Get(json,
-					"#(data.info.cache="+name.String()+
-						").data.info.version").String())
-			}
-			return true
-		},
-	)
-
 	tm := map[int]bool{}
-	gjson.Get(json, "#.data.info.threads").ForEach(
-		func(_, name gjson.Result) bool {
-			if !tm[int(name.Int())] {
-				threadz = append(threadz, int(name.Int()))
-				tm[int(name.Int())] = true
-			}
-			return true
-		},
-	)
-	sort.Ints(threadz)
-
-	clients = int(gjson.Get(json, "0.data.info.connections").Int())
-	coperations = int(gjson.Get(json, "0.data.info.operations").Int())
-
-	switch bench {
-	case "throughput":
-		graphThroughput()
+	var cacheOf []string // cache of each series
+	for _, rec := range g.recs {
+		info := rec.Data.Info
+		name := g.seriesName(rec)
+		if !cm[name] {
+			g.caches = append(g.caches, name)
+			cm[name] = true
+			g.versions = append(g.versions, info.Version)
+			cacheOf = append(cacheOf, info.Cache)
+		}
+		if !tm[info.Threads] {
+			g.threadz = append(g.threadz, info.Threads)
+			tm[info.Threads] = true
+		}
+	}
+	sort.Ints(g.threadz)
+	if g.nthreads == 0 {
+		g.nthreads = g.threadz[len(g.threadz)-1]
+	}
+	g.xvalues = g.fieldValues(g.xfield)
+	g.styles = st.Assign(g.caches, cacheOf)
+	g.infos = map[string]results.Info{}
+	for _, rec := range all {
+		if _, ok := g.infos[g.seriesName(rec)]; !ok {
+			g.infos[g.seriesName(rec)] = rec.Data.Info
+		}
+	}
+	if g.kindchart == "heatmap" && !cm[g.cache] {
+		fmt.Printf("invalid flag --cache='%s'\n", g.cache)
+		os.Exit(1)
+	}
+	if g.kindchart != "heatmap" && g.cache != "" {
+		fmt.Printf("--cache is only used with --chart=heatmap\n")
+		os.Exit(1)
+	}
+	if g.baseline != "" && !cm[g.baseline] {
+		fmt.Printf("invalid flag --baseline='%s'\n", g.baseline)
+		os.Exit(1)
+	}
+
+	g.clients = g.recs[0].Data.Info.Connections
+	g.coperations = g.recs[0].Data.Info.Operations
+}
+
+// describe returns the file of the graph and a function that draws it.
+func (g *graph) describe() (filename string, draw func()) {
+	switch g.bench {
 	case "latency":
-		graphLatency()
-	case "cpucycles":
-		graphCPUCycles()
+		return g.graphLatency()
+	case "cpucycles", "instructions", "ipc", "branchmisses", "pagefaults",
+		"opspercpu", "mbpercpu", "systime":
+		return g.graphPerf()
+	case "spectrum":
+		return g.graphSpectrum()
+	case "sizes":
+		return g.graphSizes()
+	case "bandwidth":
+		return g.graphBandwidth()
 	}
+	return g.graphThroughput()
 }
 
-func graphCPUCycles() {
-	filename := "graph_cpucycles-pipeline_" + fmt.Sprint(pipeline) +
-		"-kind_" + kind + "-scale_" + scale
-	if scase != "" {
-		filename += "-case_" + scase
+// specFlags are the flags of a spec that may have a list of values, such as
+// "pipeline=1,10", or a glob of the values, such as "percentile=9*".
+var specFlags = map[string][]string{
+	"bench": {"throughput", "latency", "cpucycles", "spectrum", "sizes",
+		"bandwidth", "instructions", "ipc", "branchmisses", "pagefaults",
+		"opspercpu", "mbpercpu", "systime"},
+	"which":      {"get", "set"},
+	"percentile": {"avg", "min", "max", "50", "90", "99", "999", "9999"},
+	"kind":       {"median", "average", "best", "worst"},
+	"scale":      {"logarithmic", "linear"},
+	"pipeline":   nil, // from the records
+	"threads":    nil,
+	"cache":      nil,
+}
+
+// allSpec is the spec of "all", which is every graph that bench-all.sh
+// draws. The graphs that don't use the percentile or operation are only
+// drawn once.
+const allSpec = "bench=throughput,latency,cpucycles,spectrum,bandwidth " +
+	"pipeline=* percentile=* which=* scale=*"
+
+// expandSpec returns the arguments of each graph of the spec, which are
+// flags without the leading dashes, such as "bench=latency pipeline=1". The
+// lists and globs of the spec flags are expanded into one graph for every
+// combination of their values.
+func expandSpec(spec string, all []results.Record) [][]string {
+	if strings.TrimSpace(spec) == "all" {
+		spec = allSpec
 	}
-	filename += ".png"
-	filename = filepath.Join(dir, "graphs", filename)
-	if !force {
-		_, err := os.Stat(filename)
-		if err == nil {
-			// fmt.Printf("File exists\n")
-			os.Exit(0)
+	expanded := [][]string{nil}
+	for _, arg := range splitArgs(spec) {
+		name, value, ok := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		arg = "--" + strings.TrimLeft(arg, "-")
+		choices, list := specFlags[name]
+		if !ok || !list {
+			for i := range expanded {
+				expanded[i] = append(expanded[i], arg)
+			}
+			continue
+		}
+		if choices == nil {
+			seen := map[string]bool{}
+			for _, rec := range all {
+				v, _ := rec.Data.Info.Field(name)
+				if !seen[v] {
+					seen[v] = true
+					choices = append(choices, v)
+				}
+			}
 		}
+		var values []string
+		for _, pattern := range strings.Split(value, ",") {
+			if !strings.ContainsAny(pattern, "*?[") {
+				values = append(values, pattern)
+				continue
+			}
+			var n int
+			for _, choice := range choices {
+				if ok, _ := path.Match(pattern, choice); ok {
+					values = append(values, choice)
+					n++
+				}
+			}
+			if n == 0 {
+				fmt.Printf("invalid spec '%s': no values match '%s'\n",
+					spec, pattern)
+				os.Exit(1)
+			}
+		}
+		var next [][]string
+		for _, args := range expanded {
+			for _, v := range values {
+				next = append(next, append(slices.Clone(args),
+					"--"+name+"="+v))
+			}
+		}
+		expanded = next
 	}
+	return expanded
+}
 
-	title := fmt.Sprintf("GET+SET - %d Clients - %d Ops - Pipeline %d",
-		clients, coperations*2, pipeline)
+// splitArgs splits a spec into its arguments, which are separated by spaces.
+// Quotes keep the spaces in an argument, such as in
+// annotate='threads=16:peak throughput'.
+func splitArgs(spec string) []string {
+	var args []string
+	var arg []rune
+	var quote rune
+	var inArg bool
+	for _, r := range spec {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg = append(arg, r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, string(arg))
+				arg, inArg = arg[:0], false
+			}
+		default:
+			arg, inArg = append(arg, r), true
+		}
+	}
+	if inArg {
+		args = append(args, string(arg))
+	}
+	return args
+}
 
-	ytitle := "CPU Cycles (cycles/op)"
+// tables returns true when the graph is printed as a table, instead of being
+// written to a file.
+func (g *graph) tables() bool {
+	return g.format == "text" || g.format == "markdown"
+}
 
-	res := gjson.Get(json, ""+
-		"#(data.perf.cycles!='')#|"+
-		"#(data.info.kind="+fmt.Sprint(kind)+")#|"+
-		"#(data.info.pipeline="+fmt.Sprint(pipeline)+")#")
+// exists returns true when the file exists.
+func exists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
 
-	// Colors
-	var outColors string
-	for i, cache := range caches {
-		outColors += fmt.Sprintf("    \"%s\": \"%s\",\n", cache, colors[i])
+// parseFilters parses the include, exclude, fix and annotate flags. The
+// special case of --scase=1 is an exclude filter. Each fix is an include
+// filter of a single value, which also sets the pipeline, threads or kind
+// that the graph is drawn for.
+func (g *graph) parseFilters() {
+	parse := func(name, s string) results.Filter {
+		f, err := results.ParseFilter(s)
+		if err != nil {
+			fmt.Printf("invalid flag --%s='%s'\n", name, s)
+			os.Exit(1)
+		}
+		return f
+	}
+	for _, s := range g.includes {
+		g.filters = append(g.filters, filter{match: parse("include", s)})
+	}
+	for _, s := range g.excludes {
+		g.filters = append(g.filters, filter{parse("exclude", s), true})
 	}
+	for _, s := range g.fixes {
+		f := parse("fix", s)
+		for _, cond := range f {
+			if len(cond.Values) != 1 || cond.Field == g.xfield {
+				fmt.Printf("invalid flag --fix='%s'\n", s)
+				os.Exit(1)
+			}
+			// Setting the value on an info checks that it is valid.
+			pin := results.Info{Pipeline: g.pipeline, Threads: g.nthreads,
+				Kind: g.kind}
+			if err := pin.SetField(cond.Field, cond.Values[0]); err != nil {
+				fmt.Printf("invalid flag --fix='%s'\n", s)
+				os.Exit(1)
+			}
+			g.pipeline, g.nthreads, g.kind = pin.Pipeline, pin.Threads, pin.Kind
+		}
+		g.filters = append(g.filters, filter{match: f})
+	}
+	switch g.scase {
+	case "":
+	case "1":
+		f := parse("scase", "cache=garnet,threads=1")
+		g.filters = append(g.filters, filter{f, true})
+	default:
+		fmt.Printf("invalid flag --scase='%s'\n", g.scase)
+		os.Exit(1)
+	}
+	for _, s := range g.annotates {
+		match, text, ok := strings.Cut(s, ":")
+		if !ok || text == "" {
+			fmt.Printf("invalid flag --annotate='%s'\n", s)
+			os.Exit(1)
+		}
+		g.notes = append(g.notes, note{parse("annotate", match), text})
+	}
+}
+
+// keep returns true when the run matches every include filter and none of
+// the exclude filters.
+func (g *graph) keep(info results.Info) bool {
+	for _, f := range g.filters {
+		if f.match.Match(info) == f.exclude {
+			return false
+		}
+	}
+	return true
+}
+
+// pointNote returns the annotations of the point of the series at the
+// threads, which may be one that was filtered out.
+func (g *graph) pointNote(series string, x string) string {
+	info := g.infos[series]
+	info.Threads = g.nthreads
+	info.Pipeline = g.pipeline
+	info.Kind = g.kind
+	info.SetField(g.xfield, x)
+	var texts []string
+	for _, n := range g.notes {
+		if n.match.Match(info) {
+			texts = append(texts, n.text)
+		}
+	}
+	return strings.Join(texts, "; ")
+}
+
+// seriesColor returns the color of the series at i.
+func (g *graph) seriesColor(i int) chart.Color {
+	color, err := chart.ParseColor(g.styles[i].Color)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: color of %s: %s\n", configPath, g.caches[i],
+			err)
+		os.Exit(1)
+	}
+	return color
+}
+
+// seriesName returns the name of the series that a record belongs to. When
+// results from several hosts or sweeps are combined, each cache has a series
+// per origin.
+func (g *graph) seriesName(rec results.Record) string {
+	if g.origins {
+		return rec.Data.Info.Cache + " (" + rec.Origin() + ")"
+	}
+	return rec.Data.Info.Cache
+}
 
-	// Threads
-	var outXSeries string
-	for i, threads := range threadz {
-		if i > 0 {
-			outXSeries += ", "
+// selectRuns returns the records of the chosen kind and pipeline, and of the
+// chosen threads when the x-axis is another field. Runs that collected perf
+// counters are kept apart from those that did not, because 'perf stat' adds
+// overhead to the cache.
+func (g *graph) selectRuns(withPerf bool) []results.Record {
+	var sel []results.Record
+	for _, rec := range g.recs {
+		r := rec.Data
+		if r.Perf.Empty() == withPerf || r.Info.Kind != g.kind ||
+			(g.xfield != "pipeline" && r.Info.Pipeline != g.pipeline) ||
+			(g.xfield != "threads" && r.Info.Threads != g.nthreads) {
+			continue
 		}
-		outXSeries += fmt.Sprintf("%d", threads)
+		sel = append(sel, rec)
 	}
+	return sel
+}
+
+// findRun returns the run for the series at the value of the x field.
+func (g *graph) findRun(sel []results.Record, series string, x string,
+) results.Run {
+	for _, rec := range sel {
+		v, _ := rec.Data.Info.Field(g.xfield)
+		if g.seriesName(rec) == series && v == x {
+			return rec.Data
+		}
+	}
+	return results.Run{}
+}
+
+// fieldValues returns the distinct values of the info field, in the order
+// of sortValues.
+func (g *graph) fieldValues(name string) []string {
+	var vals []string
+	seen := map[string]bool{}
+	for _, rec := range g.recs {
+		v, _ := rec.Data.Info.Field(name)
+		if !seen[v] {
+			seen[v] = true
+			vals = append(vals, v)
+		}
+	}
+	sortValues(vals)
+	return vals
+}
+
+// sortValues sorts the values that start with a number in numeric order,
+// such as "1-64" before "1-1024" for the sizerange, and the others in
+// alphabetical order.
+func sortValues(vals []string) {
+	// The numbers in each value are compared in turn, and then the text.
+	nums := func(s string) []float64 {
+		var ns []float64
+		if s == "" || s[0] < '0' || s[0] > '9' {
+			return nil
+		}
+		for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		}) {
+			if n, err := strconv.ParseFloat(part, 64); err == nil {
+				ns = append(ns, n)
+			}
+		}
+		return ns
+	}
+	sort.Slice(vals, func(i, j int) bool {
+		a, b := nums(vals[i]), nums(vals[j])
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return vals[i] < vals[j]
+	})
+}
+
+// graphData returns the values of the x field and a series of benchmark data
+// for each cache. The point function returns the value for a single run.
+func (g *graph) graphData(sel []results.Record,
+	point func(r results.Run) float64,
+) (xseries []string, series []chart.Series) {
+	xseries = g.xvalues
 
 	// Benchmarks
-	var outData string
-	for _, cache := range caches {
-		outData += fmt.Sprintf("    \"%s\": [", cache)
-		res1 := res.Get("#(data.info.cache=" + cache + ")#")
-		for i, threads := range threadz {
-			if i > 0 {
-				outData += ", "
+	for i, cache := range g.caches {
+		s := chart.Series{
+			Name:  g.styles[i].Name,
+			Color: g.seriesColor(i),
+			Hatch: g.styles[i].Hatch,
+		}
+		for _, x := range g.xvalues {
+			r := g.findRun(sel, cache, x)
+			p := point(r)
+			if g.tables() && r.Info.Cache == "" {
+				// Charts draw missing runs as zero, and tables show them
+				// as missing.
+				p = math.NaN()
+			}
+			var pts []float64
+			if g.errors != "" || g.kindchart == "box" {
+				pts = runPoints(r, point)
+			}
+			if len(g.notes) > 0 {
+				s.Notes = append(s.Notes, g.pointNote(cache, x))
 			}
-			res2 := res1.Get("#(data.info.threads=" + fmt.Sprint(threads) + ")")
-			point := fmt.Sprintf("%.0f",
-				res2.Get("data.perf.cycles").Float()/float64(coperations*2))
-			if scase == "1" && cache == "garnet" && threads == 1 {
-				point = "0"
+			if g.kindchart == "box" {
+				median, q1, q3, lo, hi, outliers := box(pts)
+				s.Values = append(s.Values, median)
+				s.Q1 = append(s.Q1, q1)
+				s.Q3 = append(s.Q3, q3)
+				s.Low = append(s.Low, lo)
+				s.High = append(s.High, hi)
+				s.Outliers = append(s.Outliers, outliers)
+				continue
+			}
+			s.Values = append(s.Values, p)
+			if g.errors != "" {
+				lo, hi := g.errorBar(pts)
+				s.Low = append(s.Low, lo)
+				s.High = append(s.High, hi)
 			}
-			outData += point
 		}
-		outData += "],\n"
+		series = append(series, s)
 	}
-	drawGraph(title, ytitle, filename, outXSeries, outData, outColors, scale)
+	return xseries, series
 }
 
-func graphLatency() {
-	label := ""
-	switch which {
-	case "get":
-		which = "gets"
-		label = "GET"
-	case "set":
-		which = "sets"
-		label = "SET"
+// runPoints returns the sorted points of the numbered runs that the
+// aggregate run was chosen from, without the runs that have no point. The
+// runs are read from the runs directory the first time they're needed.
+func runPoints(r results.Run, point func(r results.Run) float64) []float64 {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	if runs == nil {
+		var err error
+		runs, err = results.ReadRunDir(filepath.Join(dir, "runs"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+	var pts []float64
+	for _, run := range runs[r.Key()] {
+		if run.Info.Host != r.Info.Host || run.Info.Sweep != r.Info.Sweep {
+			continue
+		}
+		if p := point(run); !math.IsNaN(p) {
+			pts = append(pts, p)
+		}
+	}
+	sort.Float64s(pts)
+	return pts
+}
+
+// graphFile returns the path of the graph with the base name, which is
+// followed by the options that change how the graph is drawn.
+func (g *graph) graphFile(name string) string {
+	if g.xfield != "threads" {
+		name += "-x_" + g.xfield + "-threads_" + fmt.Sprint(g.nthreads)
+	}
+	for _, s := range g.fixes {
+		name += "-fix_" + slug(s)
+	}
+	if g.kindchart != "bar" {
+		name += "-chart_" + g.kindchart
+	}
+	if g.cache != "" {
+		name += "-cache_" + g.cache
+	}
+	if g.baseline != "" {
+		name += "-baseline_" + g.baseline + "-relative_" + g.relative
+	}
+	if g.errors != "" {
+		name += "-errors_" + g.errors
+	}
+	if g.scase != "" {
+		name += "-case_" + g.scase
+	}
+	for _, s := range g.includes {
+		name += "-include_" + slug(s)
+	}
+	for _, s := range g.excludes {
+		name += "-exclude_" + slug(s)
+	}
+	for _, s := range g.annotates {
+		name += "-note_" + slug(s)
+	}
+	switch g.format {
+	case "text":
+		name += ".txt"
+	case "markdown":
+		name += ".md"
 	default:
-		fmt.Printf("invalid flag --which='%s'\n", which)
-		os.Exit(1)
+		name += "." + g.format
+	}
+	return filepath.Join(dir, "graphs", name)
+}
+
+// slug returns the text in a form for a filename, such as
+// "cache-garnet-threads-1" for "cache=garnet,threads=1".
+func slug(text string) string {
+	var b []byte
+	for _, c := range []byte(strings.ToLower(text)) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '.':
+			b = append(b, c)
+		case len(b) > 0 && b[len(b)-1] != '-':
+			b = append(b, '-')
+		}
+	}
+	return strings.TrimSuffix(string(b), "-")
+}
+
+// heatmap returns true when the chart is a heatmap, which has a cell for
+// every threads and pipeline.
+func (g *graph) heatmap() bool {
+	return g.kindchart == "heatmap" || g.kindchart == "winner"
+}
+
+// pipelineName returns the pipeline for the filename of a graph.
+func (g *graph) pipelineName() string {
+	if g.heatmap() || g.xfield == "pipeline" {
+		return "all"
+	}
+	return fmt.Sprint(g.pipeline)
+}
+
+// pipelineTitle returns the pipeline for the title of a graph.
+func (g *graph) pipelineTitle() string {
+	if g.heatmap() {
+		return "All Pipelines"
 	}
-	pwhich := ""
-	plabel := ""
-	switch ppp {
+	return fmt.Sprintf("Pipeline %d", g.pipeline)
+}
+
+// graphTitle returns the title of a graph of the operations, such as
+// "GET - 256 Clients - 25600000 Ops - Pipeline 1". The field on the x-axis
+// is left out, and the threads are added when they're pinned.
+func (g *graph) graphTitle(label string, ops int) string {
+	parts := []string{label}
+	if g.xfield != "connections" {
+		parts = append(parts, fmt.Sprintf("%d Clients", g.clients))
+	}
+	if g.xfield != "operations" {
+		parts = append(parts, fmt.Sprintf("%d Ops", ops))
+	}
+	if g.xfield != "pipeline" {
+		parts = append(parts, g.pipelineTitle())
+	}
+	if g.xfield != "threads" {
+		parts = append(parts, fmt.Sprintf("%d Threads", g.nthreads))
+	}
+	return strings.Join(parts, " - ")
+}
+
+// xTitle returns the title of the x-axis.
+func (g *graph) xTitle() string {
+	if g.bench == "sizes" {
+		return "Value Size (bytes)"
+	}
+	switch g.xfield {
+	case "pipeline":
+		return "Pipeline"
+	case "sizerange":
+		return "Value Size (bytes)"
+	case "connections":
+		return "Connections"
+	case "bench_threads":
+		return "Benchmark Threads"
+	case "operations":
+		return "Operations"
+	case "version":
+		return "Version"
+	}
+	return "Threads"
+}
+
+// plot draws the graph of the runs, with or without perf counters. The point
+// function returns the value for a single run.
+func (g *graph) plot(title, ytitle, filename string, withPerf bool,
+	point func(r results.Run) float64,
+) {
+	if g.heatmap() {
+		g.drawHeatmap(title, ytitle, filename, withPerf, point)
+		return
+	}
+	xseries, series := g.graphData(g.selectRuns(withPerf), point)
+	if g.baseline != "" {
+		ytitle = g.relativeTo(series, ytitle)
+	}
+	g.drawGraph(title, ytitle, filename, xseries, series)
+}
+
+// relativeTo changes the values of the series to be relative to the
+// baseline at each point, as a ratio or as the percent difference. It
+// returns the title of the relative values. Points without a baseline, or
+// without a value, are left out.
+func (g *graph) relativeTo(series []chart.Series, ytitle string) string {
+	var base []float64
+	for i, s := range series {
+		if g.caches[i] == g.baseline {
+			base = append(base, s.Values...)
+		}
+	}
+	rel := func(vals []float64, i int) {
+		for j, v := range vals {
+			b := base[i]
+			switch {
+			case b == 0 || math.IsNaN(b) || v == 0:
+				vals[j] = math.NaN()
+			case g.relative == "percent":
+				vals[j] = (v/b - 1) * 100
+			default:
+				vals[j] = v / b
+			}
+		}
+	}
+	for _, s := range series {
+		for i := range s.Values {
+			rel(s.Values[i:i+1], i)
+			if i < len(s.Low) {
+				rel(s.Low[i:i+1], i)
+				rel(s.High[i:i+1], i)
+			}
+			if i < len(s.Q1) {
+				rel(s.Q1[i:i+1], i)
+				rel(s.Q3[i:i+1], i)
+			}
+			if i < len(s.Outliers) {
+				rel(s.Outliers[i], i)
+			}
+		}
+	}
+	// The units are left out, such as "Throughput" from
+	// "Throughput (Kops/sec)".
+	if i := strings.Index(ytitle, " ("); i != -1 {
+		ytitle = ytitle[:i]
+	}
+	if g.relative == "percent" {
+		return fmt.Sprintf("%s vs %s (%% difference)", ytitle, g.baseline)
+	}
+	return fmt.Sprintf("%s vs %s (ratio)", ytitle, g.baseline)
+}
+
+// higherBetter returns true when higher values of the bench are better, such
+// as throughput. For the others, such as latency and cycles, lower is better.
+func (g *graph) higherBetter() bool {
+	switch g.bench {
+	case "throughput", "bandwidth", "ipc", "opspercpu", "mbpercpu":
+		return true
+	}
+	return false
+}
+
+// ratio returns a divided by b, or NaN when b is zero, so that runs without
+// the counter are left out of the graphs and tables.
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return math.NaN()
+	}
+	return a / b
+}
+
+// graphPerf returns the file and the drawing of a graph of the perf counters,
+// which are of both the GET and SET operations.
+func (g *graph) graphPerf() (filename string, draw func()) {
+	filename = "graph_" + g.bench + "-pipeline_" + g.pipelineName() +
+		"-kind_" + g.kind + "-scale_" + g.scale
+	filename = g.graphFile(filename)
+
+	title := g.graphTitle("GET+SET", g.coperations*2)
+
+	ops := float64(g.coperations * 2)
+	var ytitle string
+	var point func(r results.Run) float64
+	switch g.bench {
+	case "cpucycles":
+		ytitle = "CPU Cycles (cycles/op)"
+		point = func(r results.Run) float64 {
+			return math.Round(r.Perf.Cycles / ops)
+		}
+	case "instructions":
+		ytitle = "Instructions (instructions/op)"
+		point = func(r results.Run) float64 {
+			return math.Round(r.Perf.Instructions / ops)
+		}
+	case "ipc":
+		ytitle = "IPC (instructions/cycle)"
+		point = func(r results.Run) float64 {
+			return ratio(r.Perf.Instructions, r.Perf.Cycles)
+		}
+	case "branchmisses":
+		// Some CPUs, and most VMs, don't count branches. Those runs are
+		// left out.
+		ytitle = "Branch Misses (% of branches)"
+		point = func(r results.Run) float64 {
+			return ratio(r.Perf.BranchMisses*100, r.Perf.Branches)
+		}
+	case "pagefaults":
+		ytitle = "Page Faults (faults/million ops)"
+		point = func(r results.Run) float64 {
+			return r.Perf.PageFaults / ops * 1e6
+		}
+	case "opspercpu":
+		// The throughput of the perf runs, which is a little lower than the
+		// others because of the overhead of 'perf stat'.
+		ytitle = "Throughput per CPU (Kops/sec/CPU)"
+		point = func(r results.Run) float64 {
+			opsec := (r.Sets.Opsec + r.Gets.Opsec) / 1000
+			return math.Round(ratio(opsec, r.Perf.CPUUtilized))
+		}
+	case "mbpercpu":
+		// The bandwidth of both operations, for how many bytes a cache
+		// moves for the CPU that it uses, which matters with large values.
+		ytitle = "Bandwidth per CPU (MB/sec/CPU)"
+		point = func(r results.Run) float64 {
+			mbsec := r.Sets.Mbsec + r.Gets.Mbsec
+			return math.Round(ratio(mbsec, r.Perf.CPUUtilized))
+		}
+	case "systime":
+		ytitle = "System Time (% of CPU time)"
+		point = func(r results.Run) float64 {
+			return ratio(r.Perf.SecsSys*100, r.Perf.SecsUser+r.Perf.SecsSys)
+		}
+	}
+	return filename, func() {
+		g.plot(title, ytitle, filename, true, point)
+	}
+}
+
+// percentile returns the latency field of the --percentile, such as
+// "p99_00", and its label, such as "P99".
+func (g *graph) percentile() (field, label string) {
+	switch g.ppp {
 	case "min":
-		pwhich = "min"
-		plabel = "MIN"
+		return "min", "MIN"
 	case "max":
-		pwhich = "max"
-		plabel = "MAX"
+		return "max", "MAX"
 	case "avg":
-		pwhich = "avg"
-		plabel = "AVG"
+		return "avg", "AVG"
 	case "50":
-		pwhich = "p50_00"
-		plabel = "P50"
+		return "p50_00", "P50"
 	case "90":
-		pwhich = "p90_00"
-		plabel = "P90"
+		return "p90_00", "P90"
 	case "99":
-		pwhich = "p99_00"
-		plabel = "P99"
+		return "p99_00", "P99"
 	case "999":
-		pwhich = "p99_90"
-		plabel = "P999"
+		return "p99_90", "P999"
 	case "9999":
-		pwhich = "p99_99"
-		plabel = "P9999"
+		return "p99_99", "P9999"
+	}
+	fmt.Printf("invalid flag --percentile='%s'\n", g.ppp)
+	os.Exit(1)
+	return "", ""
+}
+
+func (g *graph) graphLatency() (filename string, draw func()) {
+	label := ""
+	switch g.which {
+	case "get":
+		g.which = "gets"
+		label = "GET"
+	case "set":
+		g.which = "sets"
+		label = "SET"
 	default:
-		fmt.Printf("invalid flag --percentile='%s'\n", ppp)
+		fmt.Printf("invalid flag --which='%s'\n", g.which)
 		os.Exit(1)
 	}
+	pwhich, plabel := g.percentile()
+
+	filename = "graph_latency_" + pwhich + "-which_" + g.which +
+		"-pipeline_" + g.pipelineName() + "-kind_" + g.kind +
+		"-scale_" + g.scale
+	filename = g.graphFile(filename)
 
-	filename := "graph_latency_" + pwhich + "-which_" + which +
-		"-pipeline_" + fmt.Sprint(pipeline) + "-kind_" + kind +
-		"-scale_" + scale
-	if scase != "" {
-		filename += "-case_" + scase
+	title := g.graphTitle(label, g.coperations)
+
+	ytitle := fmt.Sprintf("%s Latency (microseconds)", plabel)
+
+	return filename, func() {
+		g.plot(title, ytitle, filename, false, func(r results.Run) float64 {
+			v, _ := r.Value(g.which + ".latency." + pwhich)
+			return math.Round(v * 1000)
+		})
 	}
-	filename += ".png"
-	filename = filepath.Join(dir, "graphs", filename)
-	if !force {
-		_, err := os.Stat(filename)
-		if err == nil {
-			// fmt.Printf("File exists\n")
-			os.Exit(0)
-		}
+}
+
+func (g *graph) graphThroughput() (filename string, draw func()) {
+	label := ""
+	switch g.which {
+	case "get":
+		g.which = "gets"
+		label = "GET"
+	case "set":
+		g.which = "sets"
+		label = "SET"
+	default:
+		fmt.Printf("invalid flag --which='%s'\n", g.which)
+		os.Exit(1)
 	}
 
-	title := fmt.Sprintf("%s - %d Clients - %d Ops - Pipeline %d",
-		label, clients, coperations, pipeline)
+	filename = "graph_opsec-which_" + g.which +
+		"-pipeline_" + g.pipelineName() + "-kind_" + g.kind +
+		"-scale_" + g.scale
+	filename = g.graphFile(filename)
 
-	ytitle := fmt.Sprintf("%s Latency (microseconds)", plabel)
+	title := g.graphTitle(label, g.coperations)
 
-	res := gjson.Get(json, ""+
-		"#(data.perf.cycles!=~*)#|"+
-		"#(data.info.kind="+fmt.Sprint(kind)+")#|"+
-		"#(data.info.pipeline="+fmt.Sprint(pipeline)+")#")
+	ytitle := "Throughput (Kops/sec)"
 
-	// Colors
-	var outColors string
-	for i, cache := range caches {
-		outColors += fmt.Sprintf("    \"%s\": \"%s\",\n", cache, colors[i])
+	return filename, func() {
+		g.plot(title, ytitle, filename, false, func(r results.Run) float64 {
+			v, _ := r.Value(g.which + ".opsec")
+			return float64(int64(v) / 1000)
+		})
 	}
+}
 
-	// Threads
-	var outXSeries string
-	for i, threads := range threadz {
-		if i > 0 {
-			outXSeries += ", "
-		}
-		outXSeries += fmt.Sprintf("%d", threads)
+// graphBandwidth returns the file and the drawing of a graph of the bytes
+// that are moved each second, which is the throughput that matters when the
+// values are large. With --x=sizerange, it shows how the bandwidth grows
+// with the size of the values.
+func (g *graph) graphBandwidth() (filename string, draw func()) {
+	label := ""
+	switch g.which {
+	case "get":
+		g.which = "gets"
+		label = "GET"
+	case "set":
+		g.which = "sets"
+		label = "SET"
+	default:
+		fmt.Printf("invalid flag --which='%s'\n", g.which)
+		os.Exit(1)
 	}
 
-	// Benchmarks
-	var outData string
-	for _, cache := range caches {
-		outData += fmt.Sprintf("    \"%s\": [", cache)
-		res1 := res.Get("#(data.info.cache=" + cache + ")#")
-		for i, threads := range threadz {
-			if i > 0 {
-				outData += ", "
-			}
-			res2 := res1.Get("#(data.info.threads=" + fmt.Sprint(threads) + ")")
-			point := fmt.Sprintf("%.0f", res2.Get("data."+which+".latency."+
-				pwhich).Float()*1000)
-			if scase == "1" && cache == "garnet" && threads == 1 {
-				point = "0"
-			}
-			outData += point
+	filename = "graph_mbsec-which_" + g.which +
+		"-pipeline_" + g.pipelineName() + "-kind_" + g.kind +
+		"-scale_" + g.scale
+	filename = g.graphFile(filename)
+
+	title := g.graphTitle(label, g.coperations)
+
+	ytitle := "Bandwidth (MB/sec)"
+
+	return filename, func() {
+		g.plot(title, ytitle, filename, false, func(r results.Run) float64 {
+			v, _ := r.Value(g.which + ".mbsec")
+			return math.Round(v)
+		})
+	}
+}
+
+func (g *graph) graphSpectrum() (filename string, draw func()) {
+	label := ""
+	switch g.which {
+	case "get":
+		g.which = "gets"
+		label = "GET"
+	case "set":
+		g.which = "sets"
+		label = "SET"
+	default:
+		fmt.Printf("invalid flag --which='%s'\n", g.which)
+		os.Exit(1)
+	}
+	filename = "graph_spectrum-which_" + g.which +
+		"-threads_" + fmt.Sprint(g.nthreads) +
+		"-pipeline_" + g.pipelineName() + "-kind_" + g.kind +
+		"-scale_" + g.scale
+	filename = g.graphFile(filename)
+
+	title := fmt.Sprintf("%s - %d Clients - %d Ops - Pipeline %d - %d Threads",
+		label, g.clients, g.coperations, g.pipeline, g.nthreads)
+
+	ytitle := "Latency (microseconds)"
+
+	return filename, func() {
+		g.drawSpectrum(title, ytitle, filename)
+	}
+}
+
+// drawSpectrum draws the latency spectrum of the caches.
+func (g *graph) drawSpectrum(title, ytitle, filename string) {
+	// Each cache has a curve from its spectrum, or from the stored
+	// percentiles when the runs were made without one.
+	sel := g.selectRuns(false)
+	var curves []chart.Curve
+	for i, cache := range g.caches {
+		r := g.findRun(sel, cache, fmt.Sprint(g.nthreads))
+		if r.Info.Cache == "" {
+			continue
 		}
-		outData += "],\n"
+		stats := r.Gets
+		if g.which == "sets" {
+			stats = r.Sets
+		}
+		cv := chart.Curve{Name: g.styles[i].Name, Color: g.seriesColor(i)}
+		for _, p := range stats.Percentiles() {
+			cv.Percentiles = append(cv.Percentiles, p.P)
+			cv.Values = append(cv.Values, math.Round(p.Latency*1000))
+		}
+		curves = append(curves, cv)
 	}
-	drawGraph(title, ytitle, filename, outXSeries, outData, outColors, scale)
+	g.writeChart(&chart.Spectrum{
+		Title:  title,
+		XTitle: "Percentile",
+		YTitle: ytitle,
+		Curves: curves,
+		Log:    g.scale == "logarithmic",
+	}, filename)
 }
 
-func graphThroughput() {
+// graphSizes returns the file and the drawing of a graph of the latency of
+// each range of value sizes, for runs made with 'bench --sizebuckets'. A
+// cache with a cliff at a size, such as at the size of a buffer, shows a
+// jump that the latency of all sizes together hides.
+func (g *graph) graphSizes() (filename string, draw func()) {
 	label := ""
-	switch which {
+	switch g.which {
 	case "get":
-		which = "gets"
+		g.which = "gets"
 		label = "GET"
 	case "set":
-		which = "sets"
+		g.which = "sets"
 		label = "SET"
 	default:
-		fmt.Printf("invalid flag --which='%s'\n", which)
+		fmt.Printf("invalid flag --which='%s'\n", g.which)
 		os.Exit(1)
 	}
+	pwhich, plabel := g.percentile()
+	filename = "graph_sizes_" + pwhich + "-which_" + g.which +
+		"-threads_" + fmt.Sprint(g.nthreads) +
+		"-pipeline_" + g.pipelineName() + "-kind_" + g.kind +
+		"-scale_" + g.scale
+	filename = g.graphFile(filename)
+
+	title := fmt.Sprintf("%s - %d Clients - %d Ops - Pipeline %d - %d Threads",
+		label, g.clients, g.coperations, g.pipeline, g.nthreads)
+
+	ytitle := fmt.Sprintf("%s Latency (microseconds)", plabel)
+
+	return filename, func() {
+		g.drawSizes(title, ytitle, filename, "latency."+pwhich)
+	}
+}
 
-	filename := "graph_opsec-which_" + which +
-		"-pipeline_" + fmt.Sprint(pipeline) + "-kind_" + kind +
-		"-scale_" + scale
-	if scase != "" {
-		filename += "-case_" + scase
+// drawSizes draws the value of each range of value sizes for the caches.
+// Caches without sizes in their runs are left out.
+func (g *graph) drawSizes(title, ytitle, filename, field string) {
+	sel := g.selectRuns(false)
+	var ranges []string
+	values := make([]map[string]float64, len(g.caches))
+	for i, cache := range g.caches {
+		r := g.findRun(sel, cache, fmt.Sprint(g.nthreads))
+		stats := r.Gets
+		if g.which == "sets" {
+			stats = r.Sets
+		}
+		for _, z := range stats.Sizes {
+			if values[i] == nil {
+				values[i] = map[string]float64{}
+			}
+			if !slices.Contains(ranges, z.Sizerange) {
+				ranges = append(ranges, z.Sizerange)
+			}
+			v, _ := z.Value(field)
+			values[i][z.Sizerange] = math.Round(v * 1000)
+		}
 	}
-	filename += ".png"
-	filename = filepath.Join(dir, "graphs", filename)
-	if !force {
-		_, err := os.Stat(filename)
-		if err == nil {
-			// fmt.Printf("File exists\n")
-			os.Exit(0)
+	if len(ranges) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: no runs with sizes, which are made "+
+			"with 'bench --sizebuckets'\n", filename)
+		os.Exit(1)
+	}
+	sortValues(ranges)
+	var series []chart.Series
+	for i := range g.caches {
+		if values[i] == nil {
+			continue
+		}
+		s := chart.Series{
+			Name:  g.styles[i].Name,
+			Color: g.seriesColor(i),
+			Hatch: g.styles[i].Hatch,
 		}
+		for _, sr := range ranges {
+			v, ok := values[i][sr]
+			if !ok {
+				v = math.NaN()
+			}
+			s.Values = append(s.Values, v)
+		}
+		series = append(series, s)
 	}
+	g.drawGraph(title, ytitle, filename, ranges, series)
+}
 
-	title := fmt.Sprintf("%s - %d Clients - %d Ops - Pipeline %d",
-		label, clients, coperations, pipeline)
+func (g *graph) drawGraph(title, ytitle, filename string, xseries []string,
+	series []chart.Series,
+) {
+	xtitle := g.xTitle()
+	if g.renderer == "python" {
+		g.drawPython(title, xtitle, ytitle, filename, xseries, series)
+		return
+	}
+	var ch chart.Chart
+	switch g.kindchart {
+	case "line":
+		ch = &chart.Line{
+			Title:  title,
+			XTitle: xtitle,
+			YTitle: ytitle,
+			X:      xseries,
+			Series: series,
+			Log:    g.scale == "logarithmic",
+		}
+	case "box":
+		ch = &chart.Box{
+			Title:  title,
+			XTitle: xtitle,
+			YTitle: ytitle,
+			X:      xseries,
+			Series: series,
+			Log:    g.scale == "logarithmic",
+		}
+	default:
+		ch = &chart.Bar{
+			Title:  title,
+			XTitle: xtitle,
+			YTitle: ytitle,
+			X:      xseries,
+			Series: series,
+			Log:    g.scale == "logarithmic",
+		}
+	}
+	g.writeChart(ch, filename)
+}
 
-	ytitle := "Throughput (Kops/sec)"
+// drawHeatmap draws a heatmap of the threads and pipelines. It's either of
+// the chosen cache, or of the winner of each cell, which is the cache with
+// the best value, such as the most throughput or the least latency.
+func (g *graph) drawHeatmap(title, ytitle, filename string, withPerf bool,
+	point func(r results.Run) float64,
+) {
+	var pipelines []int
+	pm := map[int]bool{}
+	for _, rec := range g.recs {
+		if !pm[rec.Data.Info.Pipeline] {
+			pm[rec.Data.Info.Pipeline] = true
+			pipelines = append(pipelines, rec.Data.Info.Pipeline)
+		}
+	}
+	sort.Ints(pipelines)
+
+	// The values of each cache, by pipeline and then threads.
+	values := make([][][]float64, len(g.caches))
+	for _, p := range pipelines {
+		g.pipeline = p
+		sel := g.selectRuns(withPerf)
+		for i, name := range g.caches {
+			row := make([]float64, len(g.threadz))
+			for j, threads := range g.threadz {
+				r := g.findRun(sel, name, fmt.Sprint(threads))
+				row[j] = math.NaN()
+				if r.Info.Cache != "" {
+					row[j] = point(r)
+				}
+			}
+			values[i] = append(values[i], row)
+		}
+	}
+
+	hm := &chart.Heatmap{
+		Title:  title,
+		XTitle: "Threads",
+		YTitle: "Pipeline",
+		Log:    g.scale == "logarithmic",
+	}
+	for _, threads := range g.threadz {
+		hm.X = append(hm.X, fmt.Sprint(threads))
+	}
+	for _, p := range pipelines {
+		hm.Y = append(hm.Y, fmt.Sprint(p))
+	}
+	if g.kindchart == "heatmap" {
+		for i, name := range g.caches {
+			if name == g.cache {
+				hm.Values = values[i]
+				hm.Subtitle = g.styles[i].Name + ": " + ytitle
+			}
+		}
+		g.writeChart(hm, filename)
+		return
+	}
+
+	hm.Subtitle = "Winner: " + ytitle
+	var palette []chart.Color
+	for i := range g.caches {
+		palette = append(palette, g.seriesColor(i))
+		hm.Legend = append(hm.Legend, chart.Series{
+			Name: g.styles[i].Name, Color: g.seriesColor(i),
+		})
+	}
+	lower := !g.higherBetter()
+	better := func(a, b float64) bool {
+		return (lower && a < b) || (!lower && a > b)
+	}
+	for y := range pipelines {
+		vrow := make([]float64, len(g.threadz))
+		trow := make([]string, len(g.threadz))
+		crow := make([]chart.Color, len(g.threadz))
+		for x := range g.threadz {
+			best, next := -1, -1
+			for i := range g.caches {
+				v := values[i][y][x]
+				if math.IsNaN(v) || v <= 0 {
+					continue
+				}
+				if best == -1 || better(v, values[best][y][x]) {
+					best, next = i, best
+				} else if next == -1 || better(v, values[next][y][x]) {
+					next = i
+				}
+			}
+			vrow[x] = math.NaN()
+			crow[x] = chart.Gray.Alpha(0.15)
+			if best == -1 {
+				continue
+			}
+			v := values[best][y][x]
+			vrow[x] = v
+			crow[x] = palette[best]
+			trow[x] = g.styles[best].Name + "\n" + chart.Label(v)
+			if next != -1 {
+				// How far ahead of the runner-up the winner is.
+				margin := v/values[next][y][x] - 1
+				if lower {
+					margin = values[next][y][x]/v - 1
+				}
+				trow[x] += fmt.Sprintf("\n+%.0f%%", margin*100)
+			}
+		}
+		hm.Values = append(hm.Values, vrow)
+		hm.Text = append(hm.Text, trow)
+		hm.Colors = append(hm.Colors, crow)
+	}
+	g.writeChart(hm, filename)
+}
+
+// writeChart writes the chart to the file, using the Go renderer. For the
+// text formats, the table of the chart is kept to be printed instead.
+func (g *graph) writeChart(ch chart.Chart, filename string) {
+	if g.tables() {
+		g.table = chart.Table(ch, g.format == "markdown")
+		return
+	}
+	var data []byte
+	if g.format == "svg" {
+		data = chart.SVG(ch)
+	} else {
+		var err error
+		data, err = chart.PNG(ch, 1.5)
+		if err != nil {
+			panic(err)
+		}
+	}
+	err := os.WriteFile(filename, data, 0666)
+	if err != nil {
+		panic(err)
+	}
+}
 
-	res := gjson.Get(json, ""+
-		"#(data.perf.cycles!=~*)#|"+
-		"#(data.info.kind="+fmt.Sprint(kind)+")#|"+
-		"#(data.info.pipeline="+fmt.Sprint(pipeline)+")#")
+// drawPython draws the graph by generating a matplotlib script and running
+// it with python3.
+func (g *graph) drawPython(title, xtitle, ytitle, filename string,
+	xseries []string, series []chart.Series,
+) {
 	// Colors
-	var outColors string
-	for i, cache := range caches {
-		outColors += fmt.Sprintf("    \"%s\": \"%s\",\n", cache, colors[i])
+	var outColors, outHatches string
+	for i, s := range series {
+		outColors += fmt.Sprintf("    \"%s\": \"%s\",\n", s.Name,
+			g.styles[i].Color)
+		if s.Hatch != "" {
+			outHatches += fmt.Sprintf("    \"%s\": %q,\n", s.Name, s.Hatch)
+		}
 	}
 
-	// Threads
-	var outXSeries string
-	for i, threads := range threadz {
-		if i > 0 {
-			outXSeries += ", "
+	// X values, which are quoted unless they're all numbers
+	var quoted []string
+	for _, x := range xseries {
+		if _, err := strconv.ParseFloat(x, 64); err != nil {
+			quoted = nil
+			break
 		}
-		outXSeries += fmt.Sprintf("%d", threads)
+		quoted = append(quoted, x)
 	}
+	if quoted == nil {
+		for _, x := range xseries {
+			quoted = append(quoted, strconv.Quote(x))
+		}
+	}
+	outXSeries := strings.Join(quoted, ", ")
 
 	// Benchmarks
 	var outData string
-	for _, cache := range caches {
-		outData += fmt.Sprintf("    \"%s\": [", cache)
-		res1 := res.Get("#(data.info.cache=" + cache + ")#")
-		for i, threads := range threadz {
+	for _, s := range series {
+		outData += fmt.Sprintf("    \"%s\": [", s.Name)
+		for i, v := range s.Values {
 			if i > 0 {
 				outData += ", "
 			}
-			res2 := res1.Get("#(data.info.threads=" + fmt.Sprint(threads) + ")")
-			point := fmt.Sprintf("%d", res2.Get("data."+which+".opsec").Int()/1000)
-			if scase == "1" && cache == "garnet" && threads == 1 {
-				point = "0"
+			if math.IsNaN(v) {
+				// missing
+				outData += "float('nan')"
+				continue
 			}
-			outData += point
+			outData += strconv.FormatFloat(v, 'f', -1, 64)
 		}
 		outData += "],\n"
 	}
-	drawGraph(title, ytitle, filename, outXSeries, outData, outColors, scale)
-}
 
-func drawGraph(title, ytitle, filename, outXSeries, outData, outColors, scale string) {
-	xtitle := "Threads"
 	var script string
-	if scale == "linear" {
+	if g.scale == "linear" {
 		script = BarScriptLinear
 	} else {
 		script = BarScriptLogarithmic
@@ -370,18 +1656,27 @@ func drawGraph(title, ytitle, filename, outXSeries, outData, outColors, scale st
 	script = strings.Replace(script, "{{.XSERIES}}", outXSeries, -1)
 	script = strings.Replace(script, "{{.DATA}}", outData, -1)
 	script = strings.Replace(script, "{{.COLORS}}", outColors, -1)
-	err := os.WriteFile("graph.py", []byte(script), 0666)
+	script = strings.Replace(script, "{{.HATCHES}}", outHatches, -1)
+	// Each graph has its own script, as many may be drawn at once.
+	f, err := os.CreateTemp("", "graph-*.py")
+	if err != nil {
+		panic(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(script)
+	if err == nil {
+		err = f.Close()
+	}
 	if err != nil {
 		panic(err)
 	}
-	cmd := exec.Command("python3", "graph.py")
+	cmd := exec.Command("python3", f.Name())
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	err = cmd.Run()
 	if err != nil {
 		panic(err)
 	}
-	os.RemoveAll("graph.py")
 }
 
 const BarScriptLogarithmic = `
@@ -393,6 +1688,9 @@ data = {
 colors = {
     {{.COLORS}}
 }
+hatches = {
+    {{.HATCHES}}
+}
 title = "{{.TITLE}}"
 ytitle = "{{.YTITLE}}"
 xtitle = "{{.XTITLE}}"
@@ -414,14 +1712,25 @@ edgecolors = {
     for label in colors
 }
 
+# Values that are missing, or not above zero, can't be drawn on a log scale
+data = {
+    label: [v if v > 0 else float('nan') for v in vals]
+    for label, vals in data.items()
+}
+shown = [v for vals in data.values() for v in vals if not math.isnan(v)]
+if not shown:
+    shown = [1, 10]
+
 # Axes setup
 x = np.arange(len(xseries))
-width = 0.12
-max_val = max(max(vals) for vals in data.values())
+width = 0.72 / len(data)
+max_val = max(shown)
 
-min_val = min(min(vals) for vals in data.values())
+min_val = min(shown)
 top_tick = 10 ** math.ceil(math.log(max_val, 10))
 bottom_tick = 10 ** math.floor(math.log10(min_val))
+if top_tick <= bottom_tick:
+    top_tick = bottom_tick * 10
 
 # Y-ticks and quarter-decade lines
 yticks = [10 ** i for i in range(int(math.log10(bottom_tick)), int(math.log10(top_tick)) + 1)]
@@ -443,6 +1752,7 @@ for i, (label, values) in enumerate(data.items()):
         label=label,
         color=colors[label],
         edgecolor=edgecolors[label],
+        hatch=hatches.get(label),
         linewidth=1.5,
         zorder=3
     )
@@ -470,7 +1780,7 @@ plt.xlabel(xtitle, fontsize=18, fontweight='bold', labelpad=20)
 plt.title(title, fontsize=20, fontweight='bold', pad=30)
 
 # X-ticks
-plt.xticks(x + width * 2.5, xseries, fontsize=12)
+plt.xticks(x + width * (len(data) - 1) / 2, xseries, fontsize=12)
 for label in plt.gca().get_xticklabels():
     label.set_y(-0.02)
 for label in plt.gca().get_yticklabels():
@@ -526,6 +1836,9 @@ data = {
 colors = {
     {{.COLORS}}
 }
+hatches = {
+    {{.HATCHES}}
+}
 title = "{{.TITLE}}"
 ytitle = "{{.YTITLE}}"
 xtitle = "{{.XTITLE}}"
@@ -549,8 +1862,9 @@ edgecolors = {
 
 # Axes setup
 x = np.arange(len(xseries))
-width = 0.12
-max_val = max(max(vals) for vals in data.values())
+width = 0.72 / len(data)
+shown = [v for vals in data.values() for v in vals if not math.isnan(v)]
+max_val = max(shown) if shown else 1
 
 
 # Main linear ticks
@@ -576,6 +1890,7 @@ for i, (label, values) in enumerate(data.items()):
         label=label,
         color=colors[label],
         edgecolor=edgecolors[label],
+        hatch=hatches.get(label),
         linewidth=1.5,
         zorder=3
     )
@@ -596,7 +1911,7 @@ plt.xlabel(xtitle, fontsize=18, fontweight='bold', labelpad=20)
 plt.title(title, fontsize=20, fontweight='bold', pad=30)
 
 # X-ticks and formatting
-plt.xticks(x + width * 2.5, xseries, fontsize=12)
+plt.xticks(x + width * (len(data) - 1) / 2, xseries, fontsize=12)
 for label in plt.gca().get_xticklabels():
     label.set_y(-0.02)
 for label in plt.gca().get_yticklabels():
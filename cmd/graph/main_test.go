@@ -0,0 +1,27 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSortValues(t *testing.T) {
+	tests := []struct {
+		vals, want []string
+	}{
+		{[]string{"16", "2", "1", "8"}, []string{"1", "2", "8", "16"}},
+		{[]string{"1-1024", "1-64", "65-128", "1-64x"},
+			[]string{"1-64", "1-64x", "1-1024", "65-128"}},
+		{[]string{"valkey", "2", "garnet"},
+			[]string{"garnet", "valkey", "2"}},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		vals := slices.Clone(tt.vals)
+		sortValues(vals)
+		if !slices.Equal(vals, tt.want) {
+			t.Errorf("sortValues(%q) is %q, want %q", tt.vals, vals,
+				tt.want)
+		}
+	}
+}
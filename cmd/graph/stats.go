@@ -0,0 +1,107 @@
+package main
+
+import "math"
+
+// quantile returns the q quantile of the sorted values, interpolating
+// between the closest two.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (sorted[i+1]-sorted[i])*(pos-float64(i))
+}
+
+// tdist are the two-sided 95% critical values of Student's t-distribution
+// for 1 to 30 degrees of freedom.
+var tdist = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// meanCI returns the 95% confidence interval of the mean of the values.
+func meanCI(values []float64) (lo, hi float64) {
+	n := len(values)
+	if n < 2 {
+		return math.NaN(), math.NaN()
+	}
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(n)
+	var ss float64
+	for _, v := range values {
+		ss += (v - mean) * (v - mean)
+	}
+	t := 1.96
+	if n-1 <= len(tdist) {
+		t = tdist[n-2]
+	}
+	e := t * math.Sqrt(ss/float64(n-1)) / math.Sqrt(float64(n))
+	return mean - e, mean + e
+}
+
+// medianCI returns the 95% confidence interval of the median of the sorted
+// values. It uses the order statistics, so it doesn't assume a distribution.
+func medianCI(sorted []float64) (lo, hi float64) {
+	n := len(sorted)
+	if n < 2 {
+		return math.NaN(), math.NaN()
+	}
+	e := 1.96 * math.Sqrt(float64(n)) / 2
+	j := int(math.Floor(float64(n)/2 - e))
+	k := int(math.Ceil(float64(n)/2 + e))
+	j = max(j, 1)
+	k = min(k, n)
+	return sorted[j-1], sorted[k-1]
+}
+
+// box returns the median and quartiles of the sorted values. The whiskers
+// reach to the furthest values within 1.5 times the interquartile range of
+// the quartiles, and the values beyond them are outliers.
+func box(sorted []float64) (median, q1, q3, lo, hi float64,
+	outliers []float64,
+) {
+	median = quantile(sorted, 0.5)
+	q1 = quantile(sorted, 0.25)
+	q3 = quantile(sorted, 0.75)
+	lo, hi = math.NaN(), math.NaN()
+	iqr := q3 - q1
+	for _, v := range sorted {
+		if v < q1-iqr*1.5 || v > q3+iqr*1.5 {
+			outliers = append(outliers, v)
+			continue
+		}
+		if math.IsNaN(lo) {
+			lo = v
+		}
+		hi = v
+	}
+	return median, q1, q3, lo, hi, outliers
+}
+
+// errorBar returns the range of the error bar for the sorted points of the
+// runs, using the --errors method.
+func (g *graph) errorBar(sorted []float64) (lo, hi float64) {
+	if len(sorted) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	switch g.errors {
+	case "minmax":
+		return sorted[0], sorted[len(sorted)-1]
+	case "iqr":
+		return quantile(sorted, 0.25), quantile(sorted, 0.75)
+	case "ci":
+		if g.kind == "average" {
+			return meanCI(sorted)
+		}
+		return medianCI(sorted)
+	}
+	return math.NaN(), math.NaN()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// near returns true when a and b are equal to within 1e-3, or both NaN.
+func near(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4}
+	tests := []struct {
+		values []float64
+		q      float64
+		want   float64
+	}{
+		{sorted, 0, 1},
+		{sorted, 0.25, 1.75},
+		{sorted, 0.5, 2.5},
+		{sorted, 0.75, 3.25},
+		{sorted, 1, 4},
+		{[]float64{7}, 0.5, 7},
+		{nil, 0.5, math.NaN()},
+	}
+	for _, tt := range tests {
+		if got := quantile(tt.values, tt.q); !near(got, tt.want) {
+			t.Errorf("quantile(%v, %v) = %v, want %v", tt.values,
+				tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestMeanCI(t *testing.T) {
+	// The mean is 3 and the standard deviation is sqrt(2.5), with a t of
+	// 2.776 for 4 degrees of freedom.
+	tests := []struct {
+		values []float64
+		lo, hi float64
+	}{
+		{[]float64{1, 2, 3, 4, 5}, 1.0371, 4.9629},
+		{[]float64{2, 2, 2}, 2, 2},
+		{[]float64{1}, math.NaN(), math.NaN()},
+	}
+	for _, tt := range tests {
+		lo, hi := meanCI(tt.values)
+		if !near(lo, tt.lo) || !near(hi, tt.hi) {
+			t.Errorf("meanCI(%v) = %v, %v, want %v, %v", tt.values,
+				lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestMedianCI(t *testing.T) {
+	seq := func(n int) []float64 {
+		var values []float64
+		for i := 1; i <= n; i++ {
+			values = append(values, float64(i))
+		}
+		return values
+	}
+	// The interval is between the order statistics n/2 ± 1.96*sqrt(n)/2.
+	tests := []struct {
+		values []float64
+		lo, hi float64
+	}{
+		{seq(10), 1, 9},
+		{seq(31), 10, 21},
+		{seq(3), 1, 3},
+		{seq(1), math.NaN(), math.NaN()},
+	}
+	for _, tt := range tests {
+		lo, hi := medianCI(tt.values)
+		if !near(lo, tt.lo) || !near(hi, tt.hi) {
+			t.Errorf("medianCI(%d values) = %v, %v, want %v, %v",
+				len(tt.values), lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestBox(t *testing.T) {
+	median, q1, q3, lo, hi, outliers := box([]float64{1, 2, 3, 4, 100})
+	if median != 3 || q1 != 2 || q3 != 4 || lo != 1 || hi != 4 ||
+		!slices.Equal(outliers, []float64{100}) {
+		t.Errorf("box = %v, %v, %v, %v, %v, %v, "+
+			"want 3, 2, 4, 1, 4, [100]", median, q1, q3, lo, hi,
+			outliers)
+	}
+}
+
+func TestErrorBar(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		errors, kind string
+		lo, hi       float64
+	}{
+		{"minmax", "median", 1, 5},
+		{"iqr", "median", 2, 4},
+		{"ci", "median", 1, 5},
+		{"ci", "average", 1.0371, 4.9629},
+		{"", "median", math.NaN(), math.NaN()},
+	}
+	for _, tt := range tests {
+		g := &graph{errors: tt.errors, kind: tt.kind}
+		lo, hi := g.errorBar(sorted)
+		if !near(lo, tt.lo) || !near(hi, tt.hi) {
+			t.Errorf("errorBar %s %s = %v, %v, want %v, %v",
+				tt.errors, tt.kind, lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
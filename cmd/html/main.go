@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/cache-benchmarks/chart"
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+var dir string = "results"
+var out string
+var title string = "Cache Benchmarks"
+var configPath string = "config.jsonc"
+
+// report is the data that is embedded in the page. Each run has the values
+// of every metric, in the same order as the fields.
+type report struct {
+	Clients    int         `json:"clients"`
+	Operations int         `json:"operations"`
+	Series     []string    `json:"series"`
+	Versions   []string    `json:"versions"`
+	Colors     []string    `json:"colors"`
+	Threads    []int       `json:"threads"`
+	Pipelines  []int       `json:"pipelines"`
+	Kinds      []string    `json:"kinds"`
+	Fields     []string    `json:"fields"`
+	Runs       []reportRun `json:"runs"`
+}
+
+type reportRun struct {
+	Series   int    `json:"s"`
+	Threads  int    `json:"t"`
+	Pipeline int    `json:"p"`
+	Kind     string `json:"k"`
+	Perf     bool   `json:"perf"`
+	Values   values `json:"v"`
+}
+
+// values are the fields of a run, where the missing ones, which are NaN, are
+// encoded as null.
+type values []float64
+
+func (vs values) MarshalJSON() ([]byte, error) {
+	dst := []byte{'['}
+	for i, v := range vs {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		if math.IsNaN(v) {
+			dst = append(dst, "null"...)
+		} else {
+			dst = strconv.AppendFloat(dst, v, 'f', -1, 64)
+		}
+	}
+	return append(dst, ']'), nil
+}
+
+func main() {
+	flag.StringVar(&dir, "dir", dir, "Results directory")
+	flag.StringVar(&out, "out", out, "output file "+
+		"(default is report.html in the results directory)")
+	flag.StringVar(&title, "title", title, "page title")
+	flag.StringVar(&configPath, "config", configPath, "config path, for the "+
+		"colors and names of the caches")
+	flag.Parse()
+
+	if out == "" {
+		out = filepath.Join(dir, "report.html")
+	}
+	recs, err := results.ReadOutput(dir + "/output.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if len(recs) == 0 {
+		fmt.Fprintf(os.Stderr, "%s/output.json: no records\n", dir)
+		os.Exit(1)
+	}
+	st, err := chart.ReadStyles(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	data, err := json.Marshal(newReport(recs, st))
+	if err != nil {
+		panic(err)
+	}
+	// The data is placed in a script element. The encoder escapes '<', which
+	// keeps the data from closing the element early.
+	page := strings.Replace(Page, "{{.TITLE}}", htmlEscape(title), -1)
+	page = strings.Replace(page, "{{.DATA}}", string(data), -1)
+	err = os.WriteFile(out, []byte(page), 0666)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;",
+		`"`, "&quot;").Replace(s)
+}
+
+// newReport returns the report for the records. As with graph, each cache
+// has a series per host or sweep when the records come from more than one,
+// and the series are styled from the config.
+func newReport(recs []results.Record, st *chart.Styles) report {
+	var origins bool
+	for _, rec := range recs {
+		if rec.Origin() != recs[0].Origin() {
+			origins = true
+			break
+		}
+	}
+	rp := report{
+		Clients:    recs[0].Data.Info.Connections,
+		Operations: recs[0].Data.Info.Operations,
+	}
+	var run results.Run
+	for _, f := range run.Fields() {
+		rp.Fields = append(rp.Fields, f.Name)
+	}
+	series := map[string]int{}
+	var caches []string // cache of each series
+	tm := map[int]bool{}
+	pm := map[int]bool{}
+	km := map[string]bool{}
+	for _, rec := range recs {
+		info := rec.Data.Info
+		name := info.Cache
+		if origins {
+			name += " (" + rec.Origin() + ")"
+		}
+		i, ok := series[name]
+		if !ok {
+			i = len(rp.Series)
+			series[name] = i
+			rp.Series = append(rp.Series, name)
+			rp.Versions = append(rp.Versions, info.Version)
+			caches = append(caches, info.Cache)
+		}
+		if !tm[info.Threads] {
+			tm[info.Threads] = true
+			rp.Threads = append(rp.Threads, info.Threads)
+		}
+		if !pm[info.Pipeline] {
+			pm[info.Pipeline] = true
+			rp.Pipelines = append(rp.Pipelines, info.Pipeline)
+		}
+		if !km[info.Kind] {
+			km[info.Kind] = true
+		}
+		run := rec.Data
+		var vs values
+		for _, f := range run.Fields() {
+			vs = append(vs, *f.Ptr)
+		}
+		rp.Runs = append(rp.Runs, reportRun{
+			Series:   i,
+			Threads:  info.Threads,
+			Pipeline: info.Pipeline,
+			Kind:     info.Kind,
+			Perf:     !run.Perf.Empty(),
+			Values:   vs,
+		})
+	}
+	for i, style := range st.Assign(rp.Series, caches) {
+		rp.Series[i] = style.Name
+		rp.Colors = append(rp.Colors, style.Color)
+	}
+	sort.Ints(rp.Threads)
+	sort.Ints(rp.Pipelines)
+	for _, kind := range results.Kinds {
+		if km[kind] {
+			rp.Kinds = append(rp.Kinds, kind)
+		}
+	}
+	return rp
+}
@@ -0,0 +1,428 @@
+package main
+
+// Page is the report template. It has no external dependencies, so the
+// generated file works offline.
+const Page = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.TITLE}}</title>
+<style>
+body {
+  font-family: Futura, "Helvetica Neue", Arial, sans-serif;
+  margin: 24px;
+  color: #000;
+  background: #fff;
+}
+h1 { font-size: 24px; margin: 0 0 16px 0; }
+#controls { display: flex; flex-wrap: wrap; gap: 12px 20px; margin-bottom: 12px; }
+#controls label { font-size: 14px; }
+#controls select { font: inherit; margin-left: 4px; }
+#controls select:disabled { opacity: 0.4; }
+#chart { max-width: 1200px; }
+#chart svg { width: 100%; height: auto; display: block; }
+#chart [data-name] { cursor: pointer; }
+#tip {
+  position: fixed;
+  pointer-events: none;
+  display: none;
+  background: rgba(255, 255, 255, 0.95);
+  border: 1px solid #888;
+  border-radius: 4px;
+  padding: 6px 8px;
+  font-size: 13px;
+  white-space: nowrap;
+}
+#versions { font-size: 13px; border-collapse: collapse; margin-top: 16px; }
+#versions td { padding: 2px 12px 2px 0; }
+#help { font-size: 13px; color: #555; }
+</style>
+</head>
+<body>
+<h1>{{.TITLE}}</h1>
+<div id="controls">
+  <label>Graph <select id="bench">
+    <option value="throughput">Throughput</option>
+    <option value="bandwidth">Bandwidth</option>
+    <option value="latency">Latency</option>
+    <option value="cpucycles">CPU Cycles</option>
+  </select></label>
+  <label>Operation <select id="which">
+    <option value="gets">GET</option>
+    <option value="sets">SET</option>
+  </select></label>
+  <label>Percentile <select id="percentile">
+    <option value="min">MIN</option>
+    <option value="max">MAX</option>
+    <option value="avg">AVG</option>
+    <option value="p50_00">P50</option>
+    <option value="p90_00">P90</option>
+    <option value="p99_00" selected>P99</option>
+    <option value="p99_90">P999</option>
+    <option value="p99_99">P9999</option>
+  </select></label>
+  <label>Pipeline <select id="pipeline"></select></label>
+  <label>Kind <select id="kind"></select></label>
+  <label>Scale <select id="scale">
+    <option value="logarithmic">Logarithmic</option>
+    <option value="linear">Linear</option>
+  </select></label>
+  <label>Chart <select id="type">
+    <option value="bar">Bar</option>
+    <option value="line">Line</option>
+  </select></label>
+</div>
+<div id="chart"></div>
+<div id="tip"></div>
+<p id="help">Hover over a bar or point to see its value. Click a cache in the
+legend to hide or show it.</p>
+<table id="versions"></table>
+<script id="data" type="application/json">{{.DATA}}</script>
+<script>
+"use strict";
+var D = JSON.parse(document.getElementById("data").textContent);
+var W = 1200, H = 700;
+var hidden = {};
+
+function $(id) { return document.getElementById(id); }
+
+function esc(s) {
+  return String(s).replace(/&/g, "&amp;").replace(/</g, "&lt;")
+    .replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+}
+
+function fill(sel, values, def) {
+  values.forEach(function (v) {
+    var o = document.createElement("option");
+    o.value = v;
+    o.textContent = v;
+    if (String(v) === String(def)) {
+      o.selected = true;
+    }
+    sel.appendChild(o);
+  });
+}
+
+// label formats a tick value with thousands separators.
+function label(v) {
+  if (v !== 0 && Math.abs(v) < 1) {
+    return String(+v.toPrecision(3));
+  }
+  return Math.round(v).toLocaleString("en-US");
+}
+
+// axis returns a value axis that fits the values, using the same layout as
+// the graph command. Logarithmic axes span whole decades with lines at every
+// eighth of a decade. Linear axes start at zero and have 20 labeled lines,
+// each divided into quarters.
+function axis(values, log) {
+  var a = { log: log, min: 0, max: 0, major: [], minor: [] };
+  var lo = 0, hi = 0, e, i, j;
+  values.forEach(function (v) {
+    if (isNaN(v) || (log && v <= 0)) {
+      return;
+    }
+    if (lo === 0 || v < lo) {
+      lo = v;
+    }
+    if (v > hi) {
+      hi = v;
+    }
+  });
+  if (log) {
+    if (hi === 0) {
+      lo = 1;
+      hi = 10;
+    }
+    var e0 = Math.floor(Math.log10(lo));
+    var e1 = Math.ceil(Math.log10(hi));
+    if (e1 <= e0) {
+      e1 = e0 + 1;
+    }
+    a.min = Math.pow(10, e0);
+    a.max = Math.pow(10, e1);
+    for (e = e0; e <= e1; e++) {
+      a.major.push(Math.pow(10, e));
+    }
+    for (e = e0; e < e1; e += 0.125) {
+      if (e !== Math.floor(e)) {
+        a.minor.push(Math.pow(10, e));
+      }
+    }
+    return a;
+  }
+  if (hi === 0) {
+    hi = 1;
+  }
+  a.max = hi * 1.1;
+  var n = 20, step = a.max / (n - 1);
+  for (i = 0; i < n; i++) {
+    a.major.push(step * i);
+    if (i < n - 1) {
+      for (j = 1; j < 4; j++) {
+        a.minor.push(step * i + step * j / 4);
+      }
+    }
+  }
+  return a;
+}
+
+function pos(a, v, f) {
+  var t;
+  if (a.log) {
+    v = Math.max(v, a.min);
+    t = (Math.log10(v) - Math.log10(a.min)) /
+      (Math.log10(a.max) - Math.log10(a.min));
+  } else {
+    t = (v - a.min) / (a.max - a.min);
+  }
+  return f.bottom - t * (f.bottom - f.top);
+}
+
+function darken(hex) {
+  var n = parseInt(hex.slice(1), 16);
+  var c = [(n >> 16) & 255, (n >> 8) & 255, n & 255].map(function (v) {
+    return Math.round(v * 0.4);
+  });
+  return "rgb(" + c.join(",") + ")";
+}
+
+// graph returns the title, value axis title and series of the selected
+// graph, with the same values as the graph command.
+function graph() {
+  var bench = $("bench").value;
+  var which = $("which").value;
+  var pct = $("percentile").value;
+  var pipeline = +$("pipeline").value;
+  var kind = $("kind").value;
+  var op = which === "gets" ? "GET" : "SET";
+  var g = {};
+  var field, point, perf = false;
+  if (bench === "throughput") {
+    field = which + ".opsec";
+    point = function (v) { return Math.trunc(v / 1000); };
+    g.title = op + " - " + D.clients + " Clients - " + D.operations +
+      " Ops - Pipeline " + pipeline;
+    g.ytitle = "Throughput (Kops/sec)";
+  } else if (bench === "bandwidth") {
+    field = which + ".mbsec";
+    point = function (v) { return Math.round(v); };
+    g.title = op + " - " + D.clients + " Clients - " + D.operations +
+      " Ops - Pipeline " + pipeline;
+    g.ytitle = "Bandwidth (MB/sec)";
+  } else if (bench === "latency") {
+    field = which + ".latency." + pct;
+    point = function (v) { return Math.round(v * 1000); };
+    g.title = op + " - " + D.clients + " Clients - " + D.operations +
+      " Ops - Pipeline " + pipeline;
+    g.ytitle = $("percentile").selectedOptions[0].textContent +
+      " Latency (microseconds)";
+  } else {
+    field = "perf.cycles";
+    perf = true;
+    point = function (v) { return Math.round(v / (D.operations * 2)); };
+    g.title = "GET+SET - " + D.clients + " Clients - " + D.operations * 2 +
+      " Ops - Pipeline " + pipeline;
+    g.ytitle = "CPU Cycles (cycles/op)";
+  }
+  var fi = D.fields.indexOf(field);
+  var values = D.series.map(function () {
+    return D.threads.map(function () { return NaN; });
+  });
+  D.runs.forEach(function (r) {
+    if (r.p !== pipeline || r.k !== kind || r.perf !== perf) {
+      return;
+    }
+    // Missing values, such as unsupported perf counters, are null.
+    var v = r.v[fi] === null ? NaN : point(r.v[fi]);
+    values[r.s][D.threads.indexOf(r.t)] = v;
+  });
+  g.series = [];
+  D.series.forEach(function (name, i) {
+    if (values[i].some(function (v) { return !isNaN(v); })) {
+      g.series.push({ name: name, color: D.colors[i], values: values[i] });
+    }
+  });
+  return g;
+}
+
+function text(x, y, s, attrs) {
+  return "<text x=\"" + x + "\" y=\"" + y + "\" dominant-baseline=\"central\" " +
+    (attrs || "") + ">" + esc(s) + "</text>";
+}
+
+function render() {
+  var log = $("scale").value === "logarithmic";
+  var line = $("type").value === "line";
+  var g = graph();
+  var shown = g.series.filter(function (s) { return !hidden[s.name]; });
+  var all = [];
+  shown.forEach(function (s) { all = all.concat(s.values); });
+  var a = axis(all, log);
+  var f = { left: 120, top: 100, right: W - 200, bottom: H - 90 };
+  var o = [];
+  var minorAlpha = log ? 0.3 : 0.2;
+  o.push("<svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 0 " + W +
+    " " + H + "\" font-family=\"Futura, sans-serif\">");
+  o.push("<rect width=\"" + W + "\" height=\"" + H + "\" fill=\"#fff\"/>");
+  a.minor.forEach(function (v) {
+    var y = pos(a, v, f);
+    o.push("<line x1=\"" + f.left + "\" x2=\"" + f.right + "\" y1=\"" + y +
+      "\" y2=\"" + y + "\" stroke=\"gray\" stroke-opacity=\"" + minorAlpha +
+      "\" stroke-width=\"0.5\"/>");
+    if (log) {
+      o.push(text(f.left - 8, y, label(v),
+        "font-size=\"10\" fill=\"gray\" text-anchor=\"end\""));
+    }
+  });
+  a.major.forEach(function (v) {
+    var y = pos(a, v, f);
+    o.push("<line x1=\"" + f.left + "\" x2=\"" + f.right + "\" y1=\"" + y +
+      "\" y2=\"" + y + "\" stroke=\"gray\" stroke-opacity=\"0.7\"/>");
+    if (!log && v >= 1) {
+      // linear labels are truncated
+      v = Math.trunc(v);
+    }
+    o.push(text(f.left - 14, y, label(v), "font-size=\"" + (log ? 17 : 16) +
+      "\" text-anchor=\"end\""));
+  });
+  o.push(text((f.left + f.right) / 2, 45, g.title,
+    "font-size=\"26\" font-weight=\"bold\" text-anchor=\"middle\""));
+  o.push(text((f.left + f.right) / 2, H - 35, "Threads",
+    "font-size=\"22\" font-weight=\"bold\" text-anchor=\"middle\""));
+  o.push(text(31, (f.top + f.bottom) / 2, g.ytitle,
+    "font-size=\"22\" font-weight=\"bold\" text-anchor=\"middle\" " +
+    "transform=\"rotate(-90 31 " + (f.top + f.bottom) / 2 + ")\""));
+
+  var unit = (f.right - f.left) / D.threads.length;
+  var xs = D.threads.map(function (t, i) {
+    return f.left + unit * (i + 0.5);
+  });
+  xs.forEach(function (x, i) {
+    o.push(text(x, f.bottom + 22, D.threads[i],
+      "font-size=\"16\" text-anchor=\"middle\""));
+  });
+  var valid = function (v) { return !isNaN(v) && (!log || v > 0); };
+  var tip = function (s, i) {
+    return " data-tip=\"" + esc("<b>" + esc(s.name) + "</b><br>" +
+      D.threads[i] + " threads: " + s.values[i].toLocaleString("en-US")) +
+      "\"";
+  };
+  if (line) {
+    shown.forEach(function (s) {
+      var pts = [];
+      var flush = function () {
+        if (pts.length > 1) {
+          o.push("<polyline fill=\"none\" stroke=\"" + s.color +
+            "\" stroke-width=\"2.5\" points=\"" + pts.join(" ") + "\"/>");
+        }
+        pts = [];
+      };
+      s.values.forEach(function (v, i) {
+        if (!valid(v)) {
+          flush();
+          return;
+        }
+        pts.push(xs[i] + "," + pos(a, v, f));
+      });
+      flush();
+    });
+    shown.forEach(function (s) {
+      s.values.forEach(function (v, i) {
+        if (valid(v)) {
+          o.push("<circle cx=\"" + xs[i] + "\" cy=\"" + pos(a, v, f) +
+            "\" r=\"5\" fill=\"" + s.color + "\" stroke=\"" +
+            darken(s.color) + "\" stroke-width=\"1.5\"" + tip(s, i) + "/>");
+        }
+      });
+    });
+  } else {
+    // The groups fill the same space for any number of caches, as they do
+    // in the graphs.
+    var width = unit * 0.72 / Math.max(shown.length, 1);
+    xs.forEach(function (center, i) {
+      var start = center - width * shown.length / 2;
+      shown.forEach(function (s, j) {
+        var v = s.values[i];
+        if (!valid(v)) {
+          return;
+        }
+        var y = pos(a, v, f);
+        o.push("<rect x=\"" + (start + width * j) + "\" y=\"" + y +
+          "\" width=\"" + width + "\" height=\"" + (f.bottom - y) +
+          "\" fill=\"" + s.color + "\" stroke=\"" + darken(s.color) +
+          "\" stroke-width=\"1.5\"" + tip(s, i) + "/>");
+      });
+    });
+  }
+
+  // Legend, including the hidden caches so they can be shown again.
+  var spacing = 16 * 2.2;
+  var ly = (f.top + f.bottom) / 2 - spacing * (g.series.length - 1) / 2;
+  g.series.forEach(function (s) {
+    var op = hidden[s.name] ? 0.3 : 1;
+    o.push("<g data-name=\"" + esc(s.name) + "\" opacity=\"" + op + "\">");
+    o.push("<rect x=\"" + (f.right + 30) + "\" y=\"" + (ly - 8) +
+      "\" width=\"16\" height=\"16\" fill=\"" + s.color + "\" stroke=\"" +
+      darken(s.color) + "\" stroke-width=\"1.5\"/>");
+    o.push(text(f.right + 56, ly, s.name, "font-size=\"16\""));
+    o.push("</g>");
+    ly += spacing;
+  });
+  o.push("</svg>");
+  $("chart").innerHTML = o.join("\n");
+}
+
+function update() {
+  var bench = $("bench").value;
+  $("which").disabled = bench === "cpucycles";
+  $("percentile").disabled = bench !== "latency";
+  render();
+}
+
+fill($("pipeline"), D.pipelines, D.pipelines[0]);
+fill($("kind"), D.kinds, D.kinds.indexOf("median") >= 0 ? "median" : D.kinds[0]);
+["bench", "which", "percentile", "pipeline", "kind", "scale", "type"]
+  .forEach(function (id) { $(id).addEventListener("change", update); });
+
+$("chart").addEventListener("click", function (e) {
+  var el = e.target.closest("[data-name]");
+  if (el) {
+    var name = el.getAttribute("data-name");
+    hidden[name] = !hidden[name];
+    render();
+  }
+});
+$("chart").addEventListener("mousemove", function (e) {
+  var el = e.target.closest("[data-tip]");
+  var tip = $("tip");
+  if (!el) {
+    tip.style.display = "none";
+    return;
+  }
+  tip.innerHTML = el.getAttribute("data-tip");
+  tip.style.display = "block";
+  tip.style.left = (e.clientX + 14) + "px";
+  tip.style.top = (e.clientY + 14) + "px";
+});
+$("chart").addEventListener("mouseleave", function () {
+  $("tip").style.display = "none";
+});
+
+D.series.forEach(function (name, i) {
+  var tr = document.createElement("tr");
+  var td1 = document.createElement("td");
+  var td2 = document.createElement("td");
+  td1.textContent = name;
+  td2.textContent = D.versions[i];
+  tr.appendChild(td1);
+  tr.appendChild(td2);
+  $("versions").appendChild(tr);
+});
+
+update();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+var path string = "results"
+var out string
+var dryrun bool
+
+func main() {
+	flag.StringVar(&path, "path", path, "results directory to upgrade")
+	flag.StringVar(&out, "out", out, "write the upgraded directory here "+
+		"instead of in place")
+	flag.BoolVar(&dryrun, "dry-run", dryrun, "report what would be "+
+		"upgraded without writing")
+	flag.Parse()
+
+	var absout string
+	if out != "" {
+		abs1, _ := filepath.Abs(path)
+		absout, _ = filepath.Abs(out)
+		if abs1 == absout {
+			out = ""
+		}
+	}
+
+	var upgraded, current int
+	err := filepath.WalkDir(path, func(src string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, src)
+		if err != nil {
+			return err
+		}
+		dst := src
+		if out != "" {
+			dst = filepath.Join(out, rel)
+		}
+		if d.IsDir() {
+			// The output may be inside the results directory, and
+			// it's not walked into, as it's the copy being written.
+			abs, _ := filepath.Abs(src)
+			if out != "" && abs == absout {
+				return filepath.SkipDir
+			}
+			if out != "" && !dryrun {
+				return os.MkdirAll(dst, 0777)
+			}
+			return nil
+		}
+		var migrate func([]byte) ([]byte, int, error)
+		if rel == "output.json" {
+			migrate = results.MigrateOutput
+		} else if _, _, ok := results.ParseFile(d.Name()); ok &&
+			filepath.Dir(rel) == "runs" {
+			migrate = results.MigrateRun
+		}
+		if migrate == nil {
+			// Not a results file, such as a graph. Copy it as is.
+			if out != "" && !dryrun {
+				return copyfile(dst, src)
+			}
+			return nil
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		upgrade, from, err := migrate(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+		if from == results.SchemaVersion {
+			current++
+			upgrade = data
+		} else {
+			upgraded++
+			if dryrun {
+				fmt.Printf("%s: version %d -> %d\n", src, from,
+					results.SchemaVersion)
+			}
+		}
+		if dryrun || (out == "" && from == results.SchemaVersion) {
+			return nil
+		}
+		return os.WriteFile(dst, upgrade, 0666)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d files upgraded to version %d, %d already current\n",
+		upgraded, results.SchemaVersion, current)
+}
+
+func copyfile(dst, src string) error {
+	f1, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f1.Close()
+	f2, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f2, f1); err != nil {
+		f2.Close()
+		return err
+	}
+	return f2.Close()
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+var dir string = "results"
+var kind string = "median"
+var where list
+var group string
+var sel string
+var sortby string
+var limit int
+var format string = "table"
+
+// list is a flag that may be used more than once.
+type list []string
+
+func (l *list) String() string { return strings.Join(*l, " ") }
+func (l *list) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// condition matches the rows where a column compares to a value. The "="
+// and "!=" operators may have many values separated by '|', such as
+// "threads=1|2". The others compare numbers.
+type condition struct {
+	col    int
+	op     string
+	values []string
+	num    float64
+}
+
+// item is a selected column, or an aggregate of a column over each group,
+// such as "max(gets.opsec)".
+type item struct {
+	name string // as written, which is the header
+	fn   string // count, min, max, avg, median or sum, or empty
+	col  int    // or -1 for count
+	prec int    // decimal places of the values
+}
+
+// aggregates are the functions of the items.
+var aggregates = []string{"count", "min", "max", "avg", "median", "sum"}
+
+var cols = results.Columns()
+var precs = map[string]int{}
+
+func main() {
+	flag.StringVar(&dir, "dir", dir, "Results directory")
+	flag.StringVar(&kind, "kind", kind, "median,average,best,worst,all")
+	flag.Var(&where, "where", "Only the rows that match, such as "+
+		"'pipeline=1,cache=redis|valkey,gets.opsec>1000000' "+
+		"(repeatable)")
+	flag.StringVar(&group, "group", group, "Group the rows by the "+
+		"columns, such as 'cache,pipeline'")
+	flag.StringVar(&sel, "select", sel, "Columns to output, or aggregates "+
+		"of the groups: count,min,max,avg,median,sum, such as "+
+		"'cache,max(gets.opsec)' (default is the configuration and "+
+		"throughput)")
+	flag.StringVar(&sortby, "sort", sortby, "Columns to sort by, each "+
+		"descending when prefixed by '-', such as '-max(gets.opsec)'")
+	flag.IntVar(&limit, "limit", limit, "Output at most this many rows")
+	flag.StringVar(&format, "format", format, "table,markdown,csv,json")
+	flag.Parse()
+
+	var r results.Run
+	for _, f := range r.Fields() {
+		precs[f.Name] = f.Prec
+	}
+	switch kind {
+	case "median", "average", "best", "worst", "all":
+	default:
+		fmt.Printf("invalid flag --kind='%s'\n", kind)
+		os.Exit(1)
+	}
+	switch format {
+	case "table", "markdown", "csv", "json":
+	default:
+		fmt.Printf("invalid flag --format='%s'\n", format)
+		os.Exit(1)
+	}
+	if limit < 0 {
+		fmt.Printf("invalid flag --limit='%d'\n", limit)
+		os.Exit(1)
+	}
+	var conds []condition
+	for _, s := range where {
+		for _, part := range strings.Split(s, ",") {
+			cond, ok := parseCondition(part)
+			if !ok {
+				fmt.Printf("invalid flag --where='%s'\n", s)
+				os.Exit(1)
+			}
+			conds = append(conds, cond)
+		}
+	}
+	var groups []int
+	if group != "" {
+		for _, name := range strings.Split(group, ",") {
+			i := column(strings.TrimSpace(name))
+			if i == -1 {
+				fmt.Printf("invalid flag --group='%s'\n", group)
+				os.Exit(1)
+			}
+			groups = append(groups, i)
+		}
+	}
+	if sel == "" {
+		sel = "cache,threads,pipeline,perf,sets.opsec,gets.opsec"
+		if group != "" {
+			sel = group + ",count"
+		}
+	}
+	items, ok := parseItems(sel, groups)
+	if !ok {
+		fmt.Printf("invalid flag --select='%s'\n", sel)
+		os.Exit(1)
+	}
+
+	recs, err := results.ReadOutput(dir + "/output.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	var rows [][]string
+	for _, rec := range recs {
+		if kind != "all" && rec.Data.Info.Kind != kind {
+			continue
+		}
+		row := rec.Row()
+		if matchAll(conds, row) {
+			rows = append(rows, row)
+		}
+	}
+
+	header := make([]string, len(items))
+	for i, it := range items {
+		header[i] = it.name
+	}
+	out := output(items, groups, rows)
+	if sortby != "" {
+		if !sortRows(out, header, sortby) {
+			fmt.Printf("invalid flag --sort='%s'\n", sortby)
+			os.Exit(1)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	numeric := make([]bool, len(items))
+	for i, it := range items {
+		numeric[i] = it.fn != "" || cols[it.col].Numeric
+	}
+	switch format {
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(header)
+		w.WriteAll(out)
+	case "json":
+		writeJSON(header, out, numeric)
+	default:
+		writeTable(header, out, numeric, format == "markdown")
+	}
+}
+
+// column returns the index of the named column, or -1 when there is none.
+func column(name string) int {
+	for i, col := range cols {
+		if col.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseCondition parses a condition, such as "threads=1|2" or
+// "gets.opsec>=1000000".
+func parseCondition(s string) (condition, bool) {
+	for _, op := range []string{"!=", ">=", "<=", "=", ">", "<"} {
+		name, value, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+		cond := condition{col: column(strings.TrimSpace(name)), op: op}
+		value = strings.TrimSpace(value)
+		if cond.col == -1 || value == "" {
+			return cond, false
+		}
+		if op == "=" || op == "!=" {
+			for _, v := range strings.Split(value, "|") {
+				v = strings.TrimSpace(v)
+				cond.values = append(cond.values, v)
+			}
+			return cond, true
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || !cols[cond.col].Numeric {
+			return cond, false
+		}
+		cond.num = n
+		return cond, true
+	}
+	return condition{}, false
+}
+
+// match returns true when the row meets the condition. Empty values, such as
+// the perf metrics of runs without perf, don't meet any numeric condition.
+func (cond condition) match(row []string) bool {
+	v := row[cond.col]
+	switch cond.op {
+	case "=", "!=":
+		var found bool
+		for _, value := range cond.values {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		return found == (cond.op == "=")
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return false
+	}
+	switch cond.op {
+	case ">":
+		return n > cond.num
+	case ">=":
+		return n >= cond.num
+	case "<":
+		return n < cond.num
+	}
+	return n <= cond.num
+}
+
+// matchAll returns true when the row meets every condition.
+func matchAll(conds []condition, row []string) bool {
+	for _, cond := range conds {
+		if !cond.match(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseItems parses the selected columns and aggregates. When there are
+// groups or aggregates, the plain columns must be grouped by.
+func parseItems(s string, groups []int) ([]item, bool) {
+	var items []item
+	var grouped bool
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		it := item{name: name, col: -1}
+		if name == "count" {
+			it.fn = "count"
+			items = append(items, it)
+			grouped = true
+			continue
+		}
+		for _, fn := range aggregates[1:] {
+			arg, ok := strings.CutPrefix(name, fn+"(")
+			if ok && strings.HasSuffix(arg, ")") {
+				it.fn = fn
+				name = strings.TrimSuffix(arg, ")")
+				grouped = true
+				break
+			}
+		}
+		it.col = column(name)
+		if it.col == -1 || (it.fn != "" && !cols[it.col].Numeric) {
+			return nil, false
+		}
+		it.prec = precs[name]
+		items = append(items, it)
+	}
+	if grouped || len(groups) > 0 {
+		for _, it := range items {
+			if it.fn == "" && !contains(groups, it.col) {
+				return nil, false
+			}
+		}
+	}
+	return items, true
+}
+
+// contains returns true when the columns include col.
+func contains(groups []int, col int) bool {
+	for _, g := range groups {
+		if g == col {
+			return true
+		}
+	}
+	return false
+}
+
+// output returns the rows of the items. With aggregates or groups, there's
+// a row for each group, in the order that they first appear.
+func output(items []item, groups []int, rows [][]string) [][]string {
+	var grouped bool
+	for _, it := range items {
+		grouped = grouped || it.fn != ""
+	}
+	if !grouped && len(groups) == 0 {
+		var out [][]string
+		for _, row := range rows {
+			var cells []string
+			for _, it := range items {
+				cells = append(cells, row[it.col])
+			}
+			out = append(out, cells)
+		}
+		return out
+	}
+	var keys []string
+	members := map[string][][]string{}
+	for _, row := range rows {
+		var parts []string
+		for _, g := range groups {
+			parts = append(parts, row[g])
+		}
+		key := strings.Join(parts, "\x00")
+		if _, ok := members[key]; !ok {
+			keys = append(keys, key)
+		}
+		members[key] = append(members[key], row)
+	}
+	var out [][]string
+	for _, key := range keys {
+		var cells []string
+		for _, it := range items {
+			if it.fn == "" {
+				cells = append(cells, members[key][0][it.col])
+				continue
+			}
+			cells = append(cells, aggregate(it, members[key]))
+		}
+		out = append(out, cells)
+	}
+	return out
+}
+
+// aggregate returns the aggregate of the item over the rows of a group.
+// Empty values are left out, and an aggregate without values is empty.
+func aggregate(it item, rows [][]string) string {
+	if it.fn == "count" {
+		return strconv.Itoa(len(rows))
+	}
+	var vals []float64
+	for _, row := range rows {
+		if v, err := strconv.ParseFloat(row[it.col], 64); err == nil {
+			vals = append(vals, v)
+		}
+	}
+	if len(vals) == 0 {
+		return ""
+	}
+	sort.Float64s(vals)
+	var v float64
+	switch it.fn {
+	case "min":
+		v = vals[0]
+	case "max":
+		v = vals[len(vals)-1]
+	case "median":
+		v = vals[len(vals)/2]
+		if len(vals)%2 == 0 {
+			v = (vals[len(vals)/2-1] + v) / 2
+		}
+	default:
+		for _, x := range vals {
+			v += x
+		}
+		if it.fn == "avg" {
+			v /= float64(len(vals))
+		}
+	}
+	prec := it.prec
+	if it.fn == "avg" || it.fn == "median" {
+		// Averages of whole numbers keep a little of their fraction.
+		prec = max(prec, 2)
+	}
+	return strconv.FormatFloat(math.Round(v*math.Pow10(prec))/
+		math.Pow10(prec), 'f', prec, 64)
+}
+
+// sortRows sorts the rows by the columns of the header, such as
+// "cache,-threads". Values that are numbers are compared as numbers. It
+// returns false when a column isn't in the header.
+func sortRows(out [][]string, header []string, by string) bool {
+	type key struct {
+		col  int
+		desc bool
+	}
+	var keys []key
+	for _, name := range strings.Split(by, ",") {
+		name = strings.TrimSpace(name)
+		k := key{col: -1, desc: strings.HasPrefix(name, "-")}
+		name = strings.TrimPrefix(name, "-")
+		for i, h := range header {
+			if h == name {
+				k.col = i
+			}
+		}
+		if k.col == -1 {
+			return false
+		}
+		keys = append(keys, k)
+	}
+	less := func(a, b string) bool {
+		x, errx := strconv.ParseFloat(a, 64)
+		y, erry := strconv.ParseFloat(b, 64)
+		if errx == nil && erry == nil {
+			return x < y
+		}
+		return a < b
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		for _, k := range keys {
+			a, b := out[i][k.col], out[j][k.col]
+			if a == b {
+				continue
+			}
+			if k.desc {
+				return less(b, a)
+			}
+			return less(a, b)
+		}
+		return false
+	})
+	return true
+}
+
+// writeTable writes the rows with a header, as text in aligned columns with
+// the numbers aligned to the right, or as markdown.
+func writeTable(header []string, rows [][]string, numeric []bool,
+	markdown bool,
+) {
+	if markdown {
+		fmt.Printf("| %s |\n|", strings.Join(header, " | "))
+		for _, num := range numeric {
+			if num {
+				fmt.Printf(" ---: |")
+			} else {
+				fmt.Printf(" --- |")
+			}
+		}
+		fmt.Printf("\n")
+		for _, row := range rows {
+			fmt.Printf("| %s |\n", strings.Join(row, " | "))
+		}
+		return
+	}
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		var line string
+		for i, cell := range row {
+			if numeric[i] {
+				line += fmt.Sprintf("%*s  ", widths[i], cell)
+			} else {
+				line += fmt.Sprintf("%-*s  ", widths[i], cell)
+			}
+		}
+		fmt.Printf("%s\n", strings.TrimRight(line, " "))
+	}
+}
+
+// writeJSON writes the rows as an array of objects, one per line, with the
+// numeric columns as numbers and empty values as null.
+func writeJSON(header []string, rows [][]string, numeric []bool) {
+	fmt.Printf("[")
+	for i, row := range rows {
+		if i > 0 {
+			fmt.Printf(",")
+		}
+		fmt.Printf("\n  {")
+		for j, cell := range row {
+			if j > 0 {
+				fmt.Printf(",")
+			}
+			name, _ := json.Marshal(header[j])
+			value, _ := json.Marshal(cell)
+			switch {
+			case numeric[j] && cell == "":
+				value = []byte("null")
+			case numeric[j]:
+				value = []byte(cell)
+			}
+			fmt.Printf("%s:%s", name, value)
+		}
+		fmt.Printf("}")
+	}
+	fmt.Printf("\n]\n")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+// testRows returns the rows of runs of two caches at one and two threads,
+// where only the runs of valkey have perf.
+func testRows() [][]string {
+	var r results.Run
+	for _, f := range r.Fields() {
+		precs[f.Name] = f.Prec
+	}
+	var rows [][]string
+	for _, cache := range []string{"redis", "valkey"} {
+		for threads := 1; threads <= 2; threads++ {
+			r := results.Run{Info: results.Info{Cache: cache,
+				Threads: threads, Pipeline: 1, Kind: "median"}}
+			r.Gets.Opsec = float64(threads) * 1000
+			if cache == "valkey" {
+				r.Gets.Opsec += 500
+				r.Perf.Cycles = float64(threads) * 10
+				r.Perf.Branches = math.NaN()
+			}
+			rows = append(rows, results.Record{Data: r}.Row())
+		}
+	}
+	return rows
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		where string
+		want  []int // the rows that match
+	}{
+		{"cache=valkey", []int{2, 3}},
+		{"cache != redis|valkey", nil},
+		{"threads=2", []int{1, 3}},
+		{"gets.opsec>=1500", []int{1, 2, 3}},
+		{"gets.opsec<1500", []int{0}},
+		{"perf.cycles>0", []int{2, 3}},
+		{"perf.branches<=0", nil},
+	}
+	rows := testRows()
+	for _, tt := range tests {
+		cond, ok := parseCondition(tt.where)
+		if !ok {
+			t.Fatalf("invalid condition '%s'", tt.where)
+		}
+		var got []int
+		for i, row := range rows {
+			if matchAll([]condition{cond}, row) {
+				got = append(got, i)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("'%s' matches %v, want %v", tt.where, got,
+				tt.want)
+		}
+	}
+	for _, where := range []string{"color=red", "threads=", "cache>1",
+		"threads>two", "threads"} {
+		if _, ok := parseCondition(where); ok {
+			t.Errorf("parsed '%s'", where)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		sel, group, sort string
+		want             [][]string
+	}{
+		{"cache,threads,gets.opsec", "", "-gets.opsec,cache",
+			[][]string{{"valkey", "2", "2500.000"},
+				{"redis", "2", "2000.000"},
+				{"valkey", "1", "1500.000"},
+				{"redis", "1", "1000.000"}}},
+		{"cache,count,max(gets.opsec),avg(threads)", "cache",
+			"-max(gets.opsec)",
+			[][]string{{"valkey", "2", "2500.000", "1.50"},
+				{"redis", "2", "2000.000", "1.50"}}},
+		{"threads,median(gets.opsec),sum(perf.cycles)", "threads",
+			"threads",
+			[][]string{{"1", "1250.000", "10"},
+				{"2", "2250.000", "20"}}},
+		{"min(perf.branches)", "", "",
+			[][]string{{""}}},
+	}
+	rows := testRows()
+	for _, tt := range tests {
+		var groups []int
+		if tt.group != "" {
+			groups = append(groups, column(tt.group))
+		}
+		items, ok := parseItems(tt.sel, groups)
+		if !ok {
+			t.Fatalf("invalid select '%s'", tt.sel)
+		}
+		var header []string
+		for _, it := range items {
+			header = append(header, it.name)
+		}
+		out := output(items, groups, rows)
+		if tt.sort != "" && !sortRows(out, header, tt.sort) {
+			t.Fatalf("invalid sort '%s'", tt.sort)
+		}
+		if !slices.EqualFunc(out, tt.want, slices.Equal) {
+			t.Errorf("'%s' is %q, want %q", tt.sel, out, tt.want)
+		}
+	}
+	for _, sel := range []string{"color", "max(cache)", "cache,count"} {
+		if _, ok := parseItems(sel, nil); ok {
+			t.Errorf("parsed '%s'", sel)
+		}
+	}
+	if sortRows(nil, []string{"cache"}, "threads") {
+		t.Errorf("sorted by a column that isn't selected")
+	}
+}
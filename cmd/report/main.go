@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/cache-benchmarks/chart"
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+var dir string = "results"
+var out string = "."
+var readme string = "README.md"
+var kind string = "median"
+var configPath string = "config.jsonc"
+
+// sections are the graphs of each pipeline, in the order they appear.
+var sections = []struct {
+	bench string
+	title string
+}{
+	{"opsec", "Throughput"},
+	{"mbsec", "Bandwidth"},
+	{"latency_p50_00", "Latency 50th Percentile"},
+	{"latency_p90_00", "Latency 90th Percentile"},
+	{"latency_p99_00", "Latency 99th Percentile"},
+	{"latency_p99_90", "Latency 99.9th Percentile"},
+	{"latency_p99_99", "Latency 99.99th Percentile"},
+	{"latency_min", "Latency MIN"},
+	{"latency_max", "Latency MAX"},
+	{"latency_avg", "Latency AVG"},
+	{"cpucycles", "CPU Cycles"},
+}
+
+// pages are the markdown files, one for each scale.
+var pages = []struct {
+	scale string
+	file  string
+	title string
+	note  string
+}{
+	{"linear", "LINEAR.md", "Linear Scale", ""},
+	{"logarithmic", "LOGARITHMIC.md", "Logarithmic Scale",
+		"**All graphs are [logarithmic scale]" +
+			"(https://en.wikipedia.org/wiki/Logarithmic_scale).**"},
+}
+
+var graphRx = regexp.MustCompile(`^graph_(opsec|mbsec|latency_\w+|cpucycles)` +
+	`(?:-which_(sets|gets))?-pipeline_(\d+)-kind_(\w+)-scale_(\w+)\.png$`)
+
+// graph is a graph file in the graphs directory.
+type graph struct {
+	bench    string
+	which    string
+	pipeline int
+	scale    string
+	file     string
+}
+
+func main() {
+	flag.StringVar(&dir, "dir", dir, "Results directory")
+	flag.StringVar(&out, "out", out, "directory to write the markdown "+
+		"pages to")
+	flag.StringVar(&readme, "readme", readme, "README with the version "+
+		"table to fill in, or empty to leave it alone")
+	flag.StringVar(&kind, "kind", kind, "median,average,best,worst")
+	flag.StringVar(&configPath, "config", configPath, "config path, for "+
+		"the names of the caches in the version table")
+	flag.Parse()
+
+	graphs, err := readGraphs(filepath.Join(dir, "graphs"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	// Graphs are linked relative to the pages.
+	absout, err1 := filepath.Abs(out)
+	absdir, err2 := filepath.Abs(filepath.Join(dir, "graphs"))
+	rel, err := filepath.Rel(absout, absdir)
+	if err = errors.Join(err1, err2, err); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	rel = filepath.ToSlash(rel)
+
+	// Only link to the pages that have graphs.
+	var links []string
+	for _, page := range pages {
+		for _, g := range graphs {
+			if g.scale == page.scale {
+				links = append(links, fmt.Sprintf("- [All Benchmarks %s](%s)",
+					page.title, page.file))
+				break
+			}
+		}
+	}
+	for _, page := range pages {
+		var sel []graph
+		for _, g := range graphs {
+			if g.scale == page.scale {
+				sel = append(sel, g)
+			}
+		}
+		if len(sel) == 0 {
+			continue
+		}
+		md := markdown("Cache Benchmarks ("+page.title+")", page.note, links,
+			rel, sel)
+		err := os.WriteFile(filepath.Join(out, page.file), md, 0666)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if readme != "" {
+		recs, err := results.ReadOutput(dir + "/output.json")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		st, err := chart.ReadStyles(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		if err := fillVersions(readme, recs, st); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", readme, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// readGraphs returns the graphs of the chosen kind in the directory, sorted
+// by pipeline, section and then operation, with SET before GET.
+func readGraphs(dir string) ([]graph, error) {
+	fis, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var graphs []graph
+	for _, fi := range fis {
+		m := graphRx.FindStringSubmatch(fi.Name())
+		if m == nil || m[4] != kind || section(m[1]) == -1 {
+			continue
+		}
+		pipeline, _ := strconv.Atoi(m[3])
+		graphs = append(graphs, graph{
+			bench:    m[1],
+			which:    m[2],
+			pipeline: pipeline,
+			scale:    m[5],
+			file:     fi.Name(),
+		})
+	}
+	sort.Slice(graphs, func(i, j int) bool {
+		a, b := graphs[i], graphs[j]
+		if a.pipeline != b.pipeline {
+			return a.pipeline < b.pipeline
+		}
+		if section(a.bench) != section(b.bench) {
+			return section(a.bench) < section(b.bench)
+		}
+		return a.which > b.which
+	})
+	return graphs, nil
+}
+
+// section returns the index of the section for the bench, or -1 if there is
+// none.
+func section(bench string) int {
+	for i, s := range sections {
+		if s.bench == bench {
+			return i
+		}
+	}
+	return -1
+}
+
+// anchors counts the headings of a page, for making the same anchors that
+// GitHub does. Repeated headings get a number added to them.
+type anchors map[string]int
+
+// add returns the anchor for the heading.
+func (a anchors) add(heading string) string {
+	var id []rune
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			id = append(id, r)
+		case r == ' ':
+			id = append(id, '-')
+		}
+	}
+	s := string(id)
+	n := a[s]
+	a[s]++
+	if n > 0 {
+		s += "-" + strconv.Itoa(n)
+	}
+	return s
+}
+
+// markdown returns a page with the graphs, grouped by pipeline and then by
+// section, following a table of contents.
+func markdown(title, note string, links []string, rel string, graphs []graph,
+) []byte {
+	var toc, body bytes.Buffer
+	ids := anchors{}
+	var pipeline, sect = -1, -1
+	for _, g := range graphs {
+		if g.pipeline != pipeline {
+			pipeline, sect = g.pipeline, -1
+			heading := fmt.Sprintf("Pipeline %d", pipeline)
+			ids.add(heading)
+			if toc.Len() > 0 {
+				toc.WriteString("\n\n")
+			}
+			fmt.Fprintf(&toc, "**%s**:", heading)
+			fmt.Fprintf(&body, "\n## %s\n", heading)
+		}
+		if s := section(g.bench); s != sect {
+			heading := sections[s].title
+			if sect == -1 {
+				toc.WriteString(" ")
+			} else {
+				toc.WriteString(",\n")
+			}
+			sect = s
+			fmt.Fprintf(&toc, "[%s](#%s)", heading, ids.add(heading))
+			fmt.Fprintf(&body, "\n## %s\n\n", heading)
+		}
+		fmt.Fprintf(&body, "![Alt text](%s/%s)\n", rel, g.file)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", title)
+	buf.WriteString(strings.Join(links, "\n") + "\n\n")
+	if note != "" {
+		buf.WriteString(note + "\n\n")
+	}
+	buf.WriteString("## Contents\n\n")
+	buf.Write(toc.Bytes())
+	buf.WriteString("\n\n")
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// fillVersions replaces the cache version table in the README with the
+// versions of the caches in the records. The rows are in the order of the
+// caches, so that the table only changes when a version does. Each is named
+// by the display name of the cache in the config, or else by the name that
+// the cache reported with its version.
+func fillVersions(path string, recs []results.Record, st *chart.Styles,
+) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "| CACHE | VERSION |") {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return fmt.Errorf("missing the '| CACHE | VERSION |' table")
+	}
+	end := start + 1
+	for end < len(lines) && strings.HasPrefix(lines[end], "|") {
+		end++
+	}
+	type row struct{ cache, name, version string }
+	var rows []row
+	seen := map[row]bool{}
+	for _, rec := range recs {
+		name, ver := splitVersion(rec.Data.Info.Version)
+		if ver == "" {
+			name, ver = rec.Data.Info.Cache, rec.Data.Info.Version
+		}
+		r := row{rec.Data.Info.Cache, name, ver}
+		if !seen[r] {
+			seen[r] = true
+			rows = append(rows, r)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].cache != rows[j].cache {
+			return rows[i].cache < rows[j].cache
+		}
+		return rows[i].version < rows[j].version
+	})
+	table := []string{"| CACHE | VERSION |", "| ----- | ------- |"}
+	for _, r := range rows {
+		name := r.name
+		if style := st.Caches[r.cache]; style.Name != "" {
+			name = style.Name
+		}
+		table = append(table, "| "+name+" | "+r.version+" |")
+	}
+	lines = append(lines[:start], append(table, lines[end:]...)...)
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0666)
+}
+
+// splitVersion splits the version that a cache reports into the name of the
+// program and its version. Such as "Redis server v=8.2.1 ..." into "Redis"
+// and "v=8.2.1 ...".
+func splitVersion(version string) (name, ver string) {
+	fields := strings.Fields(version)
+	if len(fields) < 2 {
+		return version, ""
+	}
+	name, fields = fields[0], fields[1:]
+	if len(fields) > 1 && strings.EqualFold(fields[0], "server") {
+		fields = fields[1:]
+	} else if len(name) > 6 && strings.HasSuffix(name, "Server") {
+		name = strings.TrimSuffix(name, "Server")
+	}
+	return name, strings.Join(fields, " ")
+}
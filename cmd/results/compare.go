@@ -0,0 +1,235 @@
+package results
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Delta is the change of a metric for one configuration, from the records
+// of one result set, A, to those of another, B.
+type Delta struct {
+	Key    Key
+	Info   Info    // of the aggregate run in B
+	Metric string  // such as "gets.opsec"
+	A, B   float64 // values of the aggregate runs
+	Change float64 // (B - A) / A, or NaN when A is zero
+	Shift  int     // 1 when B is higher, -1 when lower, 0 when neither
+	P      float64 // p-value of the numbered runs, or NaN without them
+	Q      float64 // P adjusted for the number of deltas, or NaN
+	RunsA  int     // number of runs in A
+	RunsB  int     // number of runs in B
+}
+
+// Significant returns true when the adjusted p-value of the change is below
+// alpha.
+func (d Delta) Significant(alpha float64) bool {
+	return !math.IsNaN(d.Q) && d.Q < alpha
+}
+
+// Better returns true when the change is an improvement, such as more
+// throughput or less latency.
+func (d Delta) Better() bool {
+	if HigherBetter(d.Metric) {
+		return d.Shift > 0
+	}
+	return d.Shift < 0
+}
+
+// Worse returns true when the change is a regression. A change without a
+// direction is neither better nor worse.
+func (d Delta) Worse() bool {
+	return d.Shift != 0 && !d.Better()
+}
+
+// HigherBetter returns true when higher values of the metric are better,
+// which are the throughput metrics. For the others, such as latency and the
+// perf counters, lower is better.
+func HigherBetter(metric string) bool {
+	return strings.HasSuffix(metric, ".opsec") ||
+		strings.HasSuffix(metric, ".mbsec")
+}
+
+// Metrics returns the names of the metrics that a run has, such as
+// "gets.opsec" or "perf.cycles".
+func Metrics() []string {
+	var names []string
+	var r Run
+	for _, f := range r.Fields() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Compare aligns the records of the kind in A and B by their configuration,
+// and returns the change of each metric for every configuration that both
+// have. The perf metrics are compared on the runs that collected perf
+// counters, and the others on the runs that did not. The numbered runs, when
+// there are any, give the p-value and the direction of each change, and the
+// aggregate runs must agree with that direction. Without runs, the direction
+// is that of the aggregate runs. The p-values are adjusted for the number of
+// deltas. The deltas are in the order of the metrics, and then of the
+// configurations.
+func Compare(a, b []Record, runsA, runsB map[Key][]Run, kind string,
+	metrics []string,
+) []Delta {
+	byKey := func(recs []Record) map[Key]Run {
+		m := map[Key]Run{}
+		for _, rec := range recs {
+			if rec.Data.Info.Kind == kind {
+				m[rec.Data.Key()] = rec.Data
+			}
+		}
+		return m
+	}
+	am, bm := byKey(a), byKey(b)
+	var keys []Key
+	for key := range am {
+		if _, ok := bm[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ki, kj := keys[i], keys[j]
+		if ki.Cache != kj.Cache {
+			return ki.Cache < kj.Cache
+		}
+		if ki.Pipeline != kj.Pipeline {
+			return ki.Pipeline < kj.Pipeline
+		}
+		return ki.Threads < kj.Threads
+	})
+	points := func(runs []Run, metric string) []float64 {
+		var pts []float64
+		for _, r := range runs {
+			// Runs that are missing the value, such as a perf counter
+			// that is not supported, are left out.
+			if v, _ := r.Value(metric); !math.IsNaN(v) {
+				pts = append(pts, v)
+			}
+		}
+		return pts
+	}
+	var deltas []Delta
+	for _, metric := range metrics {
+		perf := "no"
+		if strings.HasPrefix(metric, "perf.") {
+			perf = "yes"
+		}
+		for _, key := range keys {
+			if key.Perf != perf {
+				continue
+			}
+			d := Delta{Key: key, Info: bm[key].Info, Metric: metric,
+				Change: math.NaN()}
+			d.A, _ = am[key].Value(metric)
+			d.B, _ = bm[key].Value(metric)
+			if d.A != 0 {
+				d.Change = (d.B - d.A) / d.A
+			}
+			d.Shift = sign(d.B - d.A)
+			pa, pb := points(runsA[key], metric), points(runsB[key], metric)
+			d.RunsA, d.RunsB = len(pa), len(pb)
+			var shift int
+			d.P, shift = MannWhitney(pa, pb)
+			if !math.IsNaN(d.P) && shift != d.Shift {
+				d.Shift = 0
+			}
+			deltas = append(deltas, d)
+		}
+	}
+	adjust(deltas)
+	return deltas
+}
+
+// sign returns 1 when v is positive, -1 when negative, and 0 otherwise.
+func sign(v float64) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// adjust sets the Q of each delta to its p-value adjusted by the
+// Benjamini-Hochberg procedure, so that of the changes with a Q below
+// alpha, no more than alpha of them are expected to be false. Without it,
+// one in twenty of the hundreds of unchanged configurations would be
+// significant at 0.05.
+func adjust(deltas []Delta) {
+	var order []int
+	for i := range deltas {
+		deltas[i].Q = math.NaN()
+		if !math.IsNaN(deltas[i].P) {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return deltas[order[i]].P < deltas[order[j]].P
+	})
+	m := float64(len(order))
+	q := 1.0
+	for i := len(order) - 1; i >= 0; i-- {
+		d := &deltas[order[i]]
+		q = math.Min(q, d.P*m/float64(i+1))
+		d.Q = q
+	}
+}
+
+// MannWhitney returns the two-sided p-value of the Mann-Whitney U test of
+// the samples, which is whether values from one tend to be larger than those
+// from the other, and the direction of y from x: 1 when its values tend to
+// be larger, -1 when smaller, and 0 when neither. It uses the normal
+// approximation with a correction for ties, which is close for the 10 or
+// more runs that are usually made. The p-value is NaN when either sample has
+// fewer than two values.
+func MannWhitney(x, y []float64) (p float64, shift int) {
+	n1, n2 := float64(len(x)), float64(len(y))
+	if len(x) < 2 || len(y) < 2 {
+		return math.NaN(), 0
+	}
+	type value struct {
+		v     float64
+		fromX bool
+	}
+	var all []value
+	for _, v := range x {
+		all = append(all, value{v, true})
+	}
+	for _, v := range y {
+		all = append(all, value{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Tied values share the average of their ranks.
+	var r1, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].fromX {
+				r1 += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	// U counts the pairs where the value from x is larger, and it's half of
+	// the pairs when neither tends to be larger.
+	n := n1 + n2
+	u := r1 - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	shift = sign(mean - u)
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1, shift
+	}
+	z := math.Max(math.Abs(u-mean)-0.5, 0) / sigma
+	return math.Erfc(z / math.Sqrt2), shift
+}
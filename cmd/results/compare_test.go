@@ -0,0 +1,134 @@
+package results
+
+import (
+	"math"
+	"testing"
+)
+
+// near returns true when a and b are equal to within 1e-4, or both NaN.
+func near(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) < 1e-4
+}
+
+func TestMannWhitney(t *testing.T) {
+	var lo, hi []float64
+	for i := 1; i <= 10; i++ {
+		lo = append(lo, float64(i))
+		hi = append(hi, float64(i+10))
+	}
+	// The tied sample has ranks 1, 3, 3, 3, 6, 6, 6 and 8, which gives a U
+	// of 3 for x, against 8 for no difference.
+	tests := []struct {
+		x, y  []float64
+		p     float64
+		shift int
+	}{
+		{[]float64{1, 2, 2, 3}, []float64{2, 3, 3, 4}, 0.1720, 1},
+		{[]float64{2, 3, 3, 4}, []float64{1, 2, 2, 3}, 0.1720, -1},
+		{lo, hi, 0.00018, 1},
+		{[]float64{5, 5, 5}, []float64{5, 5, 5}, 1, 0},
+		{[]float64{1}, []float64{2, 3}, math.NaN(), 0},
+	}
+	for _, tt := range tests {
+		p, shift := MannWhitney(tt.x, tt.y)
+		if !near(p, tt.p) || shift != tt.shift {
+			t.Errorf("MannWhitney(%v, %v) = %v, %d, want %v, %d",
+				tt.x, tt.y, p, shift, tt.p, tt.shift)
+		}
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	ps := []float64{0.01, 0.04, 0.03, 0.005, math.NaN()}
+	want := []float64{0.02, 0.04, 0.04, 0.02, math.NaN()}
+	var deltas []Delta
+	for _, p := range ps {
+		deltas = append(deltas, Delta{P: p})
+	}
+	adjust(deltas)
+	for i, d := range deltas {
+		if !near(d.Q, want[i]) {
+			t.Errorf("p %v adjusted to %v, want %v", d.P, d.Q,
+				want[i])
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	run := func(opsec float64) Run {
+		return Run{
+			Info: Info{Cache: "valkey", Threads: 1, Pipeline: 1,
+				Kind: "median"},
+			Gets: Stats{Opsec: opsec},
+		}
+	}
+	runs := func(values ...float64) []Run {
+		var rs []Run
+		for _, v := range values {
+			rs = append(rs, run(v))
+		}
+		return rs
+	}
+	key := run(0).Key()
+	tests := []struct {
+		name         string
+		a, b         float64
+		runsA, runsB []Run
+		shift        int
+	}{
+		{"faster", 100, 120, runs(99, 100, 101), runs(119, 120, 121),
+			1},
+		{"slower", 100, 80, runs(99, 100, 101), runs(79, 80, 81), -1},
+		{"no runs", 100, 120, nil, nil, 1},
+		{"unchanged", 100, 100, nil, nil, 0},
+		// The aggregates are the same while the runs differ, and the
+		// runs are higher in B while the aggregate is lower.
+		{"same aggregates", 100, 100, runs(98, 99, 100),
+			runs(100, 101, 102), 0},
+		{"disagree", 100, 99, runs(97, 98, 100), runs(99, 101, 102), 0},
+	}
+	for _, tt := range tests {
+		a := []Record{{Data: run(tt.a)}}
+		b := []Record{{Data: run(tt.b)}}
+		deltas := Compare(a, b, map[Key][]Run{key: tt.runsA},
+			map[Key][]Run{key: tt.runsB}, "median",
+			[]string{"gets.opsec"})
+		if len(deltas) != 1 {
+			t.Fatalf("%s: %d deltas, want 1", tt.name, len(deltas))
+		}
+		d := deltas[0]
+		if d.Shift != tt.shift {
+			t.Errorf("%s: shift %d, want %d", tt.name, d.Shift,
+				tt.shift)
+		}
+		if d.Better() != (tt.shift > 0) || d.Worse() != (tt.shift < 0) {
+			t.Errorf("%s: better %v and worse %v with shift %d",
+				tt.name, d.Better(), d.Worse(), tt.shift)
+		}
+	}
+}
+
+func TestBetter(t *testing.T) {
+	tests := []struct {
+		metric        string
+		shift         int
+		better, worse bool
+	}{
+		{"gets.opsec", 1, true, false},
+		{"gets.opsec", -1, false, true},
+		{"gets.latency.p99_00", 1, false, true},
+		{"gets.latency.p99_00", -1, true, false},
+		{"gets.latency.p99_00", 0, false, false},
+	}
+	for _, tt := range tests {
+		d := Delta{Metric: tt.metric, Shift: tt.shift}
+		if d.Better() != tt.better || d.Worse() != tt.worse {
+			t.Errorf("%s shift %d: better %v and worse %v, "+
+				"want %v and %v", tt.metric, tt.shift,
+				d.Better(), d.Worse(), tt.better, tt.worse)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package results
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Key identifies the configuration that a run file belongs to.
+type Key struct {
+	Cache    string
+	Threads  int
+	Pipeline int
+	Perf     string // "yes" or "no"
+}
+
+// File returns the name of the run file for the configuration. The run is
+// either a run number or an aggregate kind, such as "median".
+func (k Key) File(run string) string {
+	return fmt.Sprintf("bench_%s-threads_%d-pipeline_%d-perf_%s-run_%s.json",
+		k.Cache, k.Threads, k.Pipeline, k.Perf, run)
+}
+
+// Key returns the configuration of the run.
+func (r Run) Key() Key {
+	perf := "yes"
+	if r.Perf.Empty() {
+		perf = "no"
+	}
+	return Key{r.Info.Cache, r.Info.Threads, r.Info.Pipeline, perf}
+}
+
+var fileRx = regexp.MustCompile(
+	`^bench_(.+)-threads_(\d+)-pipeline_(\d+)-perf_(\w+)-run_(\w+)\.json$`)
+
+// ParseFile parses the name of a run file, returning its configuration and
+// run. The run is either a run number or an aggregate kind.
+func ParseFile(name string) (key Key, run string, ok bool) {
+	m := fileRx.FindStringSubmatch(name)
+	if m == nil {
+		return Key{}, "", false
+	}
+	key.Cache = m[1]
+	key.Threads, _ = strconv.Atoi(m[2])
+	key.Pipeline, _ = strconv.Atoi(m[3])
+	key.Perf = m[4]
+	return key, m[5], true
+}
+
+// ReadRunDir reads every numbered run file in the directory, grouped by
+// configuration. Aggregate files are skipped.
+func ReadRunDir(dir string) (map[Key][]Run, error) {
+	fis, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	runs := map[Key][]Run{}
+	for _, fi := range fis {
+		key, run, ok := ParseFile(fi.Name())
+		if !ok {
+			continue
+		}
+		if _, err := strconv.Atoi(run); err != nil {
+			continue
+		}
+		r, err := ReadRun(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		runs[key] = append(runs[key], r)
+	}
+	return runs, nil
+}
@@ -0,0 +1,66 @@
+package results
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Condition matches an info field that has any of the values.
+type Condition struct {
+	Field  string
+	Values []string
+}
+
+// Filter matches runs by their info fields. It's written as conditions
+// separated by commas, each with its values separated by '|', such as
+// "cache=garnet,threads=1|2". A run matches when it meets every condition.
+type Filter []Condition
+
+// ParseFilter parses a filter, such as "cache=garnet,threads=1|2".
+func ParseFilter(s string) (Filter, error) {
+	var f Filter
+	for _, part := range strings.Split(s, ",") {
+		name, values, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || values == "" {
+			return nil, fmt.Errorf("invalid filter '%s'", s)
+		}
+		if _, ok := (Info{}).Field(name); !ok {
+			return nil, fmt.Errorf("invalid filter '%s': unknown field '%s'",
+				s, name)
+		}
+		cond := Condition{Field: name}
+		for _, v := range strings.Split(values, "|") {
+			cond.Values = append(cond.Values, strings.TrimSpace(v))
+		}
+		f = append(f, cond)
+	}
+	return f, nil
+}
+
+// Match returns true when the info meets every condition of the filter.
+func (f Filter) Match(info Info) bool {
+	for _, cond := range f {
+		v, _ := info.Field(cond.Field)
+		var ok bool
+		for _, value := range cond.Values {
+			if v == value {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the filter in the form that it's parsed from.
+func (f Filter) String() string {
+	var parts []string
+	for _, cond := range f {
+		parts = append(parts, cond.Field+"="+strings.Join(cond.Values, "|"))
+	}
+	return strings.Join(parts, ",")
+}
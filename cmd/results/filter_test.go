@@ -0,0 +1,58 @@
+package results
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	garnet := Info{Cache: "garnet", Threads: 1, Pipeline: 1}
+	valkey := Info{Cache: "valkey", Threads: 2, Pipeline: 8}
+	tests := []struct {
+		filter         string
+		garnet, valkey bool
+	}{
+		{"cache=garnet", true, false},
+		{"cache=garnet|valkey", true, true},
+		{"cache=garnet,threads=2", false, false},
+		{" threads = 1 | 2 ,pipeline=8", false, true},
+		{"kind=median", false, false},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, v := f.Match(garnet), f.Match(valkey)
+		if g != tt.garnet || v != tt.valkey {
+			t.Errorf("%q matches garnet %v and valkey %v, "+
+				"want %v and %v", tt.filter, g, v, tt.garnet,
+				tt.valkey)
+		}
+		want := strings.ReplaceAll(tt.filter, " ", "")
+		if s := f.String(); s != want {
+			t.Errorf("%q is written as %q, want %q", tt.filter, s,
+				want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		filter, err string
+	}{
+		{"", "invalid filter"},
+		{"cache", "invalid filter"},
+		{"=garnet", "invalid filter"},
+		{"sizerange=", "invalid filter"},
+		{"cache=garnet,", "invalid filter"},
+		{"color=red", "unknown field 'color'"},
+	}
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.filter)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseFilter(%q) error %v, want %s", tt.filter,
+				err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the version of the run file format that is written by
+// this package. It's stored in the "schema_version" field of every run file,
+// including the data of each record in the combined output. Files without
+// the field are version 1.
+//
+// Version 1 stored the perf counters of the numbered runs as the text that
+// 'perf stat' printed, such as "1234" or "<not supported>", while the
+// aggregate runs had numbers. Version 2 stores every counter as a number,
+// or null when perf could not count it.
+const SchemaVersion = 2
+
+// migrations upgrade a decoded run object from one version to the next.
+// The migration at index i upgrades version i+1 to version i+2.
+var migrations = []func(obj map[string]json.RawMessage) error{
+	migrateV1,
+}
+
+// migrateV1 converts the perf counters, which were stored as the text that
+// 'perf stat' printed, into numbers. Text that is not a number, such as
+// "<not supported>", becomes null.
+func migrateV1(obj map[string]json.RawMessage) error {
+	raw, ok := obj["perf"]
+	if !ok {
+		return nil
+	}
+	var perf map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &perf); err != nil {
+		return fmt.Errorf("perf: %w", err)
+	}
+	for name, raw := range perf {
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			perf[name] = json.RawMessage("null")
+			continue
+		}
+		perf[name] = json.RawMessage(strconv.FormatFloat(n, 'f', -1, 64))
+	}
+	obj["perf"] = marshal(perf)
+	return nil
+}
+
+// version returns the schema version of the run object.
+func version(obj map[string]json.RawMessage) (int, error) {
+	raw, ok := obj["schema_version"]
+	if !ok {
+		return 1, nil
+	}
+	var v int
+	if err := json.Unmarshal(raw, &v); err != nil || v < 1 {
+		return 0, errors.New("invalid schema_version")
+	}
+	if v > SchemaVersion {
+		return 0, fmt.Errorf("unsupported schema_version %d, expected %d "+
+			"or lower", v, SchemaVersion)
+	}
+	return v, nil
+}
+
+// migrate upgrades the run object data to the current schema version. The
+// original version is returned.
+func migrate(data []byte) ([]byte, int, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, 0, err
+	}
+	from, err := version(obj)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from == SchemaVersion {
+		return data, from, nil
+	}
+	for v := from; v < SchemaVersion; v++ {
+		if err := migrations[v-1](obj); err != nil {
+			return nil, 0, fmt.Errorf("migrate version %d: %w", v, err)
+		}
+	}
+	obj["schema_version"] = marshal(SchemaVersion)
+	return marshal(obj), from, nil
+}
+
+// MigrateRun upgrades a run file to the current schema version and returns
+// its encoding along with the version that it was upgraded from.
+func MigrateRun(data []byte) ([]byte, int, error) {
+	r, from, err := decodeRun(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r.Encode(), from, nil
+}
+
+// MigrateOutput upgrades the combined output to the current schema version
+// and returns its encoding along with the oldest version of its records.
+func MigrateOutput(data []byte) ([]byte, int, error) {
+	recs, from, err := decodeOutput(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return EncodeOutput(recs), from, nil
+}
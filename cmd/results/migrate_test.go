@@ -0,0 +1,117 @@
+package results
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// v1Run is a numbered run as bench wrote it before the schema version, with
+// the perf counters as the text that 'perf stat' printed.
+const v1Run = `{
+  "info": {"cache":"valkey","version":"8.1.1","threads":1,
+    "bench_threads":16,"connections":256,"operations":25600000,
+    "sizerange":"1-1024","pipeline":1},
+  "sets": {"opsec":199445.960,"mbsec":104.857,"latency":{"min":0.096,
+    "max":3.263,"avg":1.283,"p50_00":1.287,"p90_00":1.423,
+    "p99_00":1.495,"p99_90":1.639,"p99_99":2.447}},
+  "gets": {"opsec":218245.220,"mbsec":113.684,"latency":{"min":0.248,
+    "max":2.511,"avg":1.172,"p50_00":1.167,"p90_00":1.319,
+    "p99_00":1.391,"p99_90":1.487,"p99_99":1.895}},
+  "perf": {"cpu_utilized":"0.994","cycles":"6421512312",
+    "instructions":" 8756123451 ","branches":"<not supported>",
+    "branch_misses":"<not supported>","page_faults":"1234"}
+}`
+
+func TestMigrateRun(t *testing.T) {
+	data, from, err := MigrateRun([]byte(v1Run))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 {
+		t.Fatalf("migrated from version %d, want 1", from)
+	}
+	if !strings.Contains(string(data), `"schema_version": 2`) ||
+		!strings.Contains(string(data), `"branches":null`) {
+		t.Fatalf("migrated to\n%s", data)
+	}
+	r, from, err := decodeRun(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != SchemaVersion {
+		t.Fatalf("decoded version %d, want %d", from, SchemaVersion)
+	}
+	want := Perf{
+		CPUUtilized: 0.994, Cycles: 6421512312,
+		Instructions: 8756123451, Branches: math.NaN(),
+		BranchMisses: math.NaN(), PageFaults: 1234,
+	}
+	got, wf := r.Perf.Fields(), want.Fields()
+	for i := range got {
+		if !near(*got[i].Ptr, *wf[i].Ptr) {
+			t.Errorf("perf.%s is %v, want %v", got[i].Name,
+				*got[i].Ptr, *wf[i].Ptr)
+		}
+	}
+	if r.Gets.Opsec != 218245.22 || r.Sets.Latency.P9999 != 2.447 {
+		t.Errorf("migrated run has %+v", r)
+	}
+
+	// The run decodes the same without migrating it first, and migrating
+	// it again changes nothing.
+	old, from, err := decodeRun([]byte(v1Run))
+	if err != nil || from != 1 || !sameRun(old, r) {
+		t.Errorf("decoded version 1 as %+v, %d, %v", old, from, err)
+	}
+	again, from, err := MigrateRun(data)
+	if err != nil || from != SchemaVersion ||
+		string(again) != string(data) {
+		t.Errorf("migrated again from version %d, %v:\n%s", from, err,
+			again)
+	}
+}
+
+func TestMigrateOutput(t *testing.T) {
+	v2, _, err := MigrateRun([]byte(v1Run))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := `[{"file":"a.json","data":` + v1Run + `},` +
+		`{"file":"b.json","source":"old","data":` + string(v2) + `}]`
+	data, from, err := MigrateOutput([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 {
+		t.Fatalf("oldest version %d, want 1", from)
+	}
+	recs, from, err := decodeOutput(data)
+	if err != nil || from != SchemaVersion {
+		t.Fatalf("decoded version %d, %v", from, err)
+	}
+	if len(recs) != 2 || recs[0].File != "a.json" ||
+		recs[1].Source != "old" ||
+		!sameRun(recs[0].Data, recs[1].Data) {
+		t.Fatalf("migrated to %+v", recs)
+	}
+}
+
+func TestMigrateVersion(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{`{"schema_version":3}`, "unsupported schema_version 3"},
+		{`{"schema_version":0}`, "invalid schema_version"},
+		{`{"schema_version":"2"}`, "invalid schema_version"},
+		{`{"perf":{"cycles":[1]}}`, "expected number"},
+	}
+	for _, tt := range tests {
+		_, _, err := MigrateRun([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("MigrateRun(%s) error %v, want %s", tt.data,
+				err, tt.err)
+		}
+	}
+}
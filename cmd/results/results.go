@@ -0,0 +1,718 @@
+// Package results defines the typed schema shared by the bench, choose,
+// combine and graph commands.
+//
+// A run file is the output of a single benchmark run, or of an aggregate
+// (median, best, worst, average) chosen from many runs. The combined output
+// is an array of records, each holding the name of the file it was read from
+// along with its data.
+//
+// Files written with an older schema version are upgraded as they are
+// decoded. See SchemaVersion.
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Kinds of aggregates that are chosen from the runs of a configuration.
+var Kinds = []string{"median", "best", "worst", "average"}
+
+// Info describes the configuration of a run.
+type Info struct {
+	Cache        string `json:"cache"`
+	Version      string `json:"version"`
+	Threads      int    `json:"threads"`
+	BenchThreads int    `json:"bench_threads"`
+	Connections  int    `json:"connections"`
+	Operations   int    `json:"operations"`
+	Sizerange    string `json:"sizerange"`
+	Pipeline     int    `json:"pipeline"`
+	Host         string `json:"host,omitempty"`  // machine that ran the bench
+	Sweep        string `json:"sweep,omitempty"` // user provided sweep label
+	Kind         string `json:"kind,omitempty"`
+}
+
+// Label returns the host and sweep that produced the run, joined by a slash.
+// It's empty when neither is known.
+func (info Info) Label() string {
+	switch {
+	case info.Host != "" && info.Sweep != "":
+		return info.Host + "/" + info.Sweep
+	case info.Host != "":
+		return info.Host
+	}
+	return info.Sweep
+}
+
+// Latency holds the latency measurements, in milliseconds, of a phase.
+type Latency struct {
+	Min   float64
+	Max   float64
+	Avg   float64
+	P50   float64
+	P90   float64
+	P99   float64
+	P999  float64
+	P9999 float64
+}
+
+// Percentile is the latency, in milliseconds, at a percentile.
+type Percentile struct {
+	P       float64 // such as 99.9
+	Latency float64
+}
+
+// Stats holds the measurements of a single SET or GET phase. The optional
+// spectrum has the latency at many percentiles, in increasing order, and the
+// optional sizes have the measurements for each range of value sizes, in
+// increasing order.
+type Stats struct {
+	Opsec    float64
+	Mbsec    float64
+	Latency  Latency
+	Spectrum []Percentile
+	Sizes    []SizeStats
+}
+
+// SizeStats holds the measurements of a phase for the values in a range of
+// sizes, such as "512-1023".
+type SizeStats struct {
+	Sizerange string
+	Opsec     float64
+	Mbsec     float64
+	Latency   Latency
+}
+
+// Perf holds the counters collected by 'perf stat'. It's empty for runs that
+// did not use perf. A counter that perf could not count, such as branches in
+// most VMs, is NaN, which is stored as null.
+type Perf struct {
+	CPUUtilized  float64
+	Cycles       float64
+	Instructions float64
+	Branches     float64
+	BranchMisses float64
+	PageFaults   float64
+	SecsUser     float64
+	SecsSys      float64
+}
+
+// Run is the content of a run file.
+type Run struct {
+	Info Info  `json:"info"`
+	Sets Stats `json:"sets"`
+	Gets Stats `json:"gets"`
+	Perf Perf  `json:"perf"`
+}
+
+// Record is a single entry in the combined output. Source is the label of
+// the results directory that the record was combined from, or the directory
+// itself when it has no label and the run has no host or sweep.
+type Record struct {
+	File   string `json:"file"`
+	Source string `json:"source,omitempty"`
+	Data   Run    `json:"data"`
+}
+
+// Origin returns the source of the record, or the host and sweep that
+// produced it when it has no source. An explicit label of a directory wins,
+// so that two directories from the same host, such as before and after a
+// change, stay apart.
+func (rec Record) Origin() string {
+	if rec.Source != "" {
+		return rec.Source
+	}
+	return rec.Data.Info.Label()
+}
+
+// Field is a named numeric value in a result section. Prec is the number of
+// decimal places used when the value is encoded. Optional fields are not
+// encoded when zero.
+type Field struct {
+	Name     string
+	Ptr      *float64
+	Prec     int
+	Optional bool
+}
+
+// Fields returns the latency values in their encoding order.
+func (l *Latency) Fields() []Field {
+	return []Field{
+		{Name: "min", Ptr: &l.Min, Prec: 3},
+		{Name: "max", Ptr: &l.Max, Prec: 3},
+		{Name: "avg", Ptr: &l.Avg, Prec: 3},
+		{Name: "p50_00", Ptr: &l.P50, Prec: 3},
+		{Name: "p90_00", Ptr: &l.P90, Prec: 3},
+		{Name: "p99_00", Ptr: &l.P99, Prec: 3},
+		{Name: "p99_90", Ptr: &l.P999, Prec: 3},
+		{Name: "p99_99", Ptr: &l.P9999, Prec: 3},
+	}
+}
+
+// Fields returns the phase values in their encoding order. Latency values
+// are prefixed with "latency.".
+func (s *Stats) Fields() []Field {
+	fields := []Field{
+		{Name: "opsec", Ptr: &s.Opsec, Prec: 3},
+		{Name: "mbsec", Ptr: &s.Mbsec, Prec: 3},
+	}
+	for _, f := range s.Latency.Fields() {
+		f.Name = "latency." + f.Name
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// Fields returns the values of the size range in their encoding order.
+// Latency values are prefixed with "latency.".
+func (s *SizeStats) Fields() []Field {
+	fields := []Field{
+		{Name: "opsec", Ptr: &s.Opsec, Prec: 3},
+		{Name: "mbsec", Ptr: &s.Mbsec, Prec: 3},
+	}
+	for _, f := range s.Latency.Fields() {
+		f.Name = "latency." + f.Name
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// Value returns the numeric value at the provided path, such as
+// "latency.p99_00".
+func (s SizeStats) Value(path string) (float64, bool) {
+	for _, f := range s.Fields() {
+		if f.Name == path {
+			return *f.Ptr, true
+		}
+	}
+	return 0, false
+}
+
+// Fields returns the perf counters in their encoding order.
+func (p *Perf) Fields() []Field {
+	return []Field{
+		{Name: "cpu_utilized", Ptr: &p.CPUUtilized, Prec: 3},
+		{Name: "cycles", Ptr: &p.Cycles, Prec: 0},
+		{Name: "instructions", Ptr: &p.Instructions, Prec: 0},
+		{Name: "branches", Ptr: &p.Branches, Prec: 0},
+		{Name: "branch_misses", Ptr: &p.BranchMisses, Prec: 0},
+		{Name: "page_faults", Ptr: &p.PageFaults, Prec: 0},
+		{Name: "secsuser", Ptr: &p.SecsUser, Prec: 3, Optional: true},
+		{Name: "secssys", Ptr: &p.SecsSys, Prec: 3, Optional: true},
+	}
+}
+
+// Fields returns every numeric value of the run, prefixed by the section
+// that it belongs to. Such as "gets.opsec" or "perf.cycles".
+func (r *Run) Fields() []Field {
+	var fields []Field
+	for _, f := range r.Sets.Fields() {
+		f.Name = "sets." + f.Name
+		fields = append(fields, f)
+	}
+	for _, f := range r.Gets.Fields() {
+		f.Name = "gets." + f.Name
+		fields = append(fields, f)
+	}
+	for _, f := range r.Perf.Fields() {
+		f.Name = "perf." + f.Name
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// Value returns the numeric value at the provided path, such as "gets.opsec".
+func (r Run) Value(path string) (float64, bool) {
+	for _, f := range r.Fields() {
+		if f.Name == path {
+			return *f.Ptr, true
+		}
+	}
+	return 0, false
+}
+
+// Percentiles returns the spectrum, or the latency percentiles when there is
+// no spectrum. The minimum latency is the 0th percentile.
+func (s Stats) Percentiles() []Percentile {
+	if len(s.Spectrum) > 0 {
+		return s.Spectrum
+	}
+	l := s.Latency
+	return []Percentile{
+		{0, l.Min}, {50, l.P50}, {90, l.P90}, {99, l.P99}, {99.9, l.P999},
+		{99.99, l.P9999},
+	}
+}
+
+// Add returns the sum of each value in s and o. The spectrum latencies are
+// summed when both have the same percentiles, otherwise it's dropped, and
+// the same goes for the sizes and their ranges.
+func (s Stats) Add(o Stats) Stats {
+	af, bf := s.Fields(), o.Fields()
+	for i := range af {
+		*af[i].Ptr += *bf[i].Ptr
+	}
+	spectrum := make([]Percentile, len(s.Spectrum))
+	copy(spectrum, s.Spectrum)
+	if len(spectrum) != len(o.Spectrum) {
+		spectrum = nil
+	}
+	for i := range spectrum {
+		if spectrum[i].P != o.Spectrum[i].P {
+			spectrum = nil
+			break
+		}
+		spectrum[i].Latency += o.Spectrum[i].Latency
+	}
+	s.Spectrum = spectrum
+	sizes := make([]SizeStats, len(s.Sizes))
+	copy(sizes, s.Sizes)
+	if len(sizes) != len(o.Sizes) {
+		sizes = nil
+	}
+	for i := range sizes {
+		if sizes[i].Sizerange != o.Sizes[i].Sizerange {
+			sizes = nil
+			break
+		}
+		af, bf := sizes[i].Fields(), o.Sizes[i].Fields()
+		for j := range af {
+			*af[j].Ptr += *bf[j].Ptr
+		}
+	}
+	s.Sizes = sizes
+	return s
+}
+
+// Div returns each value in s divided by n.
+func (s Stats) Div(n float64) Stats {
+	for _, f := range s.Fields() {
+		*f.Ptr /= n
+	}
+	spectrum := make([]Percentile, len(s.Spectrum))
+	for i, p := range s.Spectrum {
+		spectrum[i] = Percentile{p.P, p.Latency / n}
+	}
+	s.Spectrum = spectrum
+	sizes := make([]SizeStats, len(s.Sizes))
+	copy(sizes, s.Sizes)
+	for i := range sizes {
+		for _, f := range sizes[i].Fields() {
+			*f.Ptr /= n
+		}
+	}
+	s.Sizes = sizes
+	return s
+}
+
+// Add returns the sum of each value in p and o.
+func (p Perf) Add(o Perf) Perf {
+	af, bf := p.Fields(), o.Fields()
+	for i := range af {
+		*af[i].Ptr += *bf[i].Ptr
+	}
+	return p
+}
+
+// Div returns each value in p divided by n.
+func (p Perf) Div(n float64) Perf {
+	for _, f := range p.Fields() {
+		*f.Ptr /= n
+	}
+	return p
+}
+
+// Empty returns true when no perf counters were collected.
+func (p Perf) Empty() bool {
+	return p == Perf{}
+}
+
+// appendFields appends the fields as a json object. Fields in a section,
+// such as "latency.min", are written to a nested object of the section, and
+// the fields of a section must follow each other.
+func appendFields(dst []byte, fields []Field) []byte {
+	dst = append(dst, '{')
+	var n int
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		section, _, nested := strings.Cut(f.Name, ".")
+		if !nested && f.Optional && *f.Ptr == 0 {
+			continue
+		}
+		if n > 0 {
+			dst = append(dst, ',')
+		}
+		n++
+		if nested {
+			sub, rest := sectionFields(fields[i:], section)
+			dst = strconv.AppendQuote(dst, section)
+			dst = append(dst, ':')
+			dst = appendFields(dst, sub)
+			i = len(fields) - len(rest) - 1
+			continue
+		}
+		dst = strconv.AppendQuote(dst, f.Name)
+		dst = append(dst, ':')
+		if math.IsNaN(*f.Ptr) {
+			dst = append(dst, "null"...)
+			continue
+		}
+		dst = strconv.AppendFloat(dst, *f.Ptr, 'f', f.Prec, 64)
+	}
+	return append(dst, '}')
+}
+
+// sectionFields returns the leading fields of the section, without the
+// section prefix, and the fields that follow them.
+func sectionFields(fields []Field, section string) (sub, rest []Field) {
+	for len(fields) > 0 {
+		name, ok := strings.CutPrefix(fields[0].Name, section+".")
+		if !ok {
+			break
+		}
+		f := fields[0]
+		f.Name = name
+		sub = append(sub, f)
+		fields = fields[1:]
+	}
+	return sub, fields
+}
+
+// unmarshalFields decodes the fields from a json object, with the fields of
+// a section in a nested object, like appendFields writes them.
+func unmarshalFields(data []byte, fields []Field) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if section, _, nested := strings.Cut(f.Name, "."); nested {
+			sub, rest := sectionFields(fields[i:], section)
+			i = len(fields) - len(rest) - 1
+			raw, ok := m[section]
+			if !ok {
+				continue
+			}
+			if err := unmarshalFields(raw, sub); err != nil {
+				return fmt.Errorf("%s: %w", section, err)
+			}
+			continue
+		}
+		raw, ok := m[f.Name]
+		if !ok {
+			continue
+		}
+		v, err := parseNumber(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		*f.Ptr = v
+	}
+	return nil
+}
+
+// parseNumber parses a json number, or null for a value that is missing,
+// which is NaN. Strings are also accepted because perf counters were
+// originally stored as the text that 'perf stat' printed, which may be
+// something like "<not supported>". Those are missing too, and not zero.
+func parseNumber(raw json.RawMessage) (float64, error) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return math.NaN(), nil
+		}
+		return n, nil
+	case nil:
+		return math.NaN(), nil
+	}
+	return 0, errors.New("expected number")
+}
+
+// MarshalJSON encodes the latency values.
+func (l Latency) MarshalJSON() ([]byte, error) {
+	return appendFields(nil, l.Fields()), nil
+}
+
+// UnmarshalJSON decodes the latency values.
+func (l *Latency) UnmarshalJSON(data []byte) error {
+	return unmarshalFields(data, l.Fields())
+}
+
+// MarshalJSON encodes the phase values, followed by the spectrum and sizes
+// when there are any.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	dst := appendFields(nil, s.Fields())
+	dst = dst[:len(dst)-1]
+	if len(s.Spectrum) > 0 {
+		dst = append(dst, `,"spectrum":[`...)
+		for i, p := range s.Spectrum {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, '[')
+			dst = strconv.AppendFloat(dst, p.P, 'f', -1, 64)
+			dst = append(dst, ',')
+			dst = strconv.AppendFloat(dst, p.Latency, 'f', 3, 64)
+			dst = append(dst, ']')
+		}
+		dst = append(dst, ']')
+	}
+	if len(s.Sizes) > 0 {
+		dst = append(dst, `,"sizes":[`...)
+		for i, z := range s.Sizes {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, marshal(z)...)
+		}
+		dst = append(dst, ']')
+	}
+	return append(dst, '}'), nil
+}
+
+// UnmarshalJSON decodes the phase values.
+func (s *Stats) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Spectrum [][2]float64 `json:"spectrum"`
+		Sizes    []SizeStats  `json:"sizes"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = Stats{Sizes: v.Sizes}
+	for _, p := range v.Spectrum {
+		s.Spectrum = append(s.Spectrum, Percentile{p[0], p[1]})
+	}
+	return unmarshalFields(data, s.Fields())
+}
+
+// MarshalJSON encodes the values of the size range.
+func (s SizeStats) MarshalJSON() ([]byte, error) {
+	dst := []byte(`{"sizerange":`)
+	dst = strconv.AppendQuote(dst, s.Sizerange)
+	dst = append(dst, ',')
+	return append(dst, appendFields(nil, s.Fields())[1:]...), nil
+}
+
+// UnmarshalJSON decodes the values of the size range.
+func (s *SizeStats) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Sizerange string `json:"sizerange"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = SizeStats{Sizerange: v.Sizerange}
+	return unmarshalFields(data, s.Fields())
+}
+
+// MarshalJSON encodes the perf counters. Empty counters are encoded as "{}".
+func (p Perf) MarshalJSON() ([]byte, error) {
+	if p.Empty() {
+		return []byte("{}"), nil
+	}
+	return appendFields(nil, p.Fields()), nil
+}
+
+// UnmarshalJSON decodes the perf counters.
+func (p *Perf) UnmarshalJSON(data []byte) error {
+	return unmarshalFields(data, p.Fields())
+}
+
+func marshal(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// The schema only has types that always encode.
+		panic(err)
+	}
+	return data
+}
+
+func (r Run) appendIndent(dst []byte, indent string) []byte {
+	dst = append(dst, "{\n"...)
+	dst = append(dst, indent+`  "schema_version": `...)
+	dst = strconv.AppendInt(dst, SchemaVersion, 10)
+	dst = append(dst, ",\n"+indent+`  "info": `...)
+	dst = append(dst, marshal(r.Info)...)
+	dst = append(dst, ",\n"+indent+`  "sets": `...)
+	dst = append(dst, marshal(r.Sets)...)
+	dst = append(dst, ",\n"+indent+`  "gets": `...)
+	dst = append(dst, marshal(r.Gets)...)
+	dst = append(dst, ",\n"+indent+`  "perf": `...)
+	dst = append(dst, marshal(r.Perf)...)
+	dst = append(dst, "\n"+indent+"}"...)
+	return dst
+}
+
+// Encode returns the run file encoding of the run. Each section is written on
+// a single line.
+func (r Run) Encode() []byte {
+	return append(r.appendIndent(nil, ""), '\n')
+}
+
+// Validate returns an error if the run is missing required information or
+// has values that are out of range.
+func (r Run) Validate() error {
+	switch {
+	case r.Info.Cache == "":
+		return errors.New("missing info.cache")
+	case r.Info.Threads <= 0:
+		return errors.New("invalid info.threads")
+	case r.Info.Pipeline <= 0:
+		return errors.New("invalid info.pipeline")
+	case r.Info.Connections <= 0:
+		return errors.New("invalid info.connections")
+	case r.Info.Operations <= 0:
+		return errors.New("invalid info.operations")
+	}
+	if r.Info.Kind != "" && !validKind(r.Info.Kind) {
+		return fmt.Errorf("invalid info.kind '%s'", r.Info.Kind)
+	}
+	for _, f := range r.Fields() {
+		if *f.Ptr < 0 {
+			return fmt.Errorf("negative %s", f.Name)
+		}
+	}
+	for _, sizes := range [][]SizeStats{r.Sets.Sizes, r.Gets.Sizes} {
+		for _, z := range sizes {
+			if z.Sizerange == "" {
+				return errors.New("missing sizes.sizerange")
+			}
+		}
+	}
+	return nil
+}
+
+func validKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeRun decodes and validates a run file.
+func DecodeRun(data []byte) (Run, error) {
+	r, _, err := decodeRun(data)
+	return r, err
+}
+
+func decodeRun(data []byte) (Run, int, error) {
+	data, from, err := migrate(data)
+	if err != nil {
+		return Run{}, 0, err
+	}
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Run{}, 0, err
+	}
+	if err := r.Validate(); err != nil {
+		return Run{}, 0, err
+	}
+	return r, from, nil
+}
+
+// ReadRun reads and decodes the run file at path.
+func ReadRun(path string) (Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Run{}, err
+	}
+	r, err := DecodeRun(data)
+	if err != nil {
+		return Run{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// WriteRun encodes the run to a file at path.
+func WriteRun(path string, r Run) error {
+	return os.WriteFile(path, r.Encode(), 0666)
+}
+
+// EncodeOutput returns the combined output encoding of the records.
+func EncodeOutput(recs []Record) []byte {
+	var dst []byte
+	dst = append(dst, "[\n"...)
+	for i, rec := range recs {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, "{\n"...)
+		dst = append(dst, `  "file": `...)
+		dst = append(dst, marshal(rec.File)...)
+		dst = append(dst, ",\n"...)
+		if rec.Source != "" {
+			dst = append(dst, `  "source": `...)
+			dst = append(dst, marshal(rec.Source)...)
+			dst = append(dst, ",\n"...)
+		}
+		dst = append(dst, `  "data": `...)
+		dst = rec.Data.appendIndent(dst, "  ")
+		dst = append(dst, "\n}"...)
+	}
+	dst = append(dst, "\n]\n"...)
+	return dst
+}
+
+// DecodeOutput decodes and validates the combined output.
+func DecodeOutput(data []byte) ([]Record, error) {
+	recs, _, err := decodeOutput(data)
+	return recs, err
+}
+
+func decodeOutput(data []byte) ([]Record, int, error) {
+	var raws []struct {
+		File   string          `json:"file"`
+		Source string          `json:"source"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, 0, err
+	}
+	recs := make([]Record, len(raws))
+	oldest := SchemaVersion
+	for i, raw := range raws {
+		r, from, err := decodeRun(raw.Data)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", raw.File, err)
+		}
+		recs[i] = Record{File: raw.File, Source: raw.Source, Data: r}
+		oldest = min(oldest, from)
+	}
+	return recs, oldest, nil
+}
+
+// ReadOutput reads and decodes the combined output file at path.
+func ReadOutput(path string) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := DecodeOutput(bytes.TrimSpace(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return recs, nil
+}
+
+// WriteOutput encodes the records to a combined output file at path.
+func WriteOutput(path string, recs []Record) error {
+	return os.WriteFile(path, EncodeOutput(recs), 0666)
+}
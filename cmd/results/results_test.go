@@ -0,0 +1,102 @@
+package results
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRun returns a run with a value in every field, along with a spectrum,
+// sizes and a perf counter that is missing.
+func testRun() Run {
+	r := Run{Info: Info{
+		Cache: "valkey", Version: "8.1.1", Threads: 4, BenchThreads: 16,
+		Connections: 256, Operations: 25600000, Sizerange: "1-1024",
+		Pipeline: 1, Host: "box", Kind: "median",
+	}}
+	for i, f := range r.Fields() {
+		*f.Ptr = float64(i + 1)
+	}
+	r.Perf.Branches = math.NaN()
+	r.Gets.Spectrum = []Percentile{{50, 1.25}, {99.9, 2.5}}
+	r.Sets.Sizes = []SizeStats{
+		{Sizerange: "1-1", Opsec: 10, Latency: Latency{P99: 0.5}},
+		{Sizerange: "2-3", Opsec: 20, Latency: Latency{P99: 0.75}},
+	}
+	return r
+}
+
+// sameRun returns true when the runs have the same values, where missing
+// values are the same as each other.
+func sameRun(a, b Run) bool {
+	af, bf := a.Fields(), b.Fields()
+	for i := range af {
+		if !near(*af[i].Ptr, *bf[i].Ptr) {
+			return false
+		}
+	}
+	return a.Info == b.Info &&
+		reflect.DeepEqual(a.Gets.Spectrum, b.Gets.Spectrum) &&
+		reflect.DeepEqual(a.Sets.Sizes, b.Sets.Sizes)
+}
+
+func TestEncodeRun(t *testing.T) {
+	r := testRun()
+	data := r.Encode()
+	if !strings.Contains(string(data), `"branches":null`) {
+		t.Fatalf("missing counter is not null:\n%s", data)
+	}
+	got, from, err := decodeRun(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != SchemaVersion || !sameRun(got, r) {
+		t.Fatalf("decoded version %d\n%+v\nwant\n%+v", from, got, r)
+	}
+	if again := got.Encode(); string(again) != string(data) {
+		t.Fatalf("encoded again as\n%s\nwant\n%s", again, data)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want float64
+		ok   bool
+	}{
+		{`1.5`, 1.5, true},
+		{`"42"`, 42, true},
+		{`" 7 "`, 7, true},
+		{`"<not supported>"`, math.NaN(), true},
+		{`null`, math.NaN(), true},
+		{`true`, 0, false},
+		{`[1]`, 0, false},
+	}
+	for _, tt := range tests {
+		v, err := parseNumber(json.RawMessage(tt.raw))
+		if (err == nil) != tt.ok || (tt.ok && !near(v, tt.want)) {
+			t.Errorf("parseNumber(%s) = %v, %v, want %v", tt.raw, v,
+				err, tt.want)
+		}
+	}
+}
+
+func TestRow(t *testing.T) {
+	r := testRun()
+	row := Record{File: "f.json", Data: r}.Row()
+	cols := Columns()
+	if len(row) != len(cols) {
+		t.Fatalf("%d values for %d columns", len(row), len(cols))
+	}
+	want := map[string]string{
+		"file": "f.json", "cache": "valkey", "threads": "4",
+		"perf": "yes", "sets.opsec": "1.000", "perf.branches": "",
+	}
+	for i, col := range cols {
+		if w, ok := want[col.Name]; ok && row[i] != w {
+			t.Errorf("%s is '%s', want '%s'", col.Name, row[i], w)
+		}
+	}
+}
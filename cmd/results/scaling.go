@@ -0,0 +1,117 @@
+package results
+
+import "math"
+
+// Scaling is a model of how the throughput of a cache grows with its
+// threads, fitted to the speedup over one thread. The Universal Scalability
+// Law gives the speedup at n threads as n / (1 + σ(n-1) + κn(n-1)), where σ
+// is the contention, the part of the work that is serial, and κ is the
+// coherency, the cost of keeping threads in agreement, which makes the
+// throughput fall past a peak. Amdahl's law is the same without κ.
+type Scaling struct {
+	Serial    float64 // σ of Amdahl's law, the serial fraction
+	Sigma     float64 // σ of the USL, the contention
+	Kappa     float64 // κ of the USL, the coherency
+	R2        float64 // coefficient of determination of the USL fit
+	AmdahlR2  float64 // coefficient of determination of the Amdahl fit
+	NumPoints int     // number of threads that the fit used
+}
+
+// FitScaling fits Amdahl's law and the USL to the speedup at each threads,
+// with least squares. Both are linear in their coefficients after
+// n/speedup - 1 is taken, which is what is fitted. The coefficients are
+// never negative, so superlinear speedup is fitted as perfect scaling. It
+// returns false when there are fewer than two threads with a speedup.
+func FitScaling(threads []int, speedup []float64) (Scaling, bool) {
+	var s Scaling
+	var a, b, c, d, e float64
+	for i, t := range threads {
+		n := float64(t)
+		if speedup[i] <= 0 || math.IsNaN(speedup[i]) {
+			continue
+		}
+		s.NumPoints++
+		x1, x2, y := n-1, n*(n-1), n/speedup[i]-1
+		a += x1 * x1
+		b += x1 * x2
+		c += x2 * x2
+		d += x1 * y
+		e += x2 * y
+	}
+	if s.NumPoints < 2 || a == 0 {
+		return s, false
+	}
+	s.Serial = math.Max(d/a, 0)
+	if det := a*c - b*b; det > 0 {
+		s.Sigma = (d*c - b*e) / det
+		s.Kappa = (a*e - b*d) / det
+	}
+	switch {
+	case s.Kappa <= 0:
+		s.Sigma, s.Kappa = s.Serial, 0
+	case s.Sigma < 0:
+		s.Sigma, s.Kappa = 0, math.Max(e/c, 0)
+	}
+	s.R2 = r2(threads, speedup, func(n float64) float64 {
+		return usl(n, s.Sigma, s.Kappa)
+	})
+	s.AmdahlR2 = r2(threads, speedup, func(n float64) float64 {
+		return usl(n, s.Serial, 0)
+	})
+	return s, true
+}
+
+// usl returns the speedup at n threads of the Universal Scalability Law.
+func usl(n, sigma, kappa float64) float64 {
+	return n / (1 + sigma*(n-1) + kappa*n*(n-1))
+}
+
+// r2 returns the coefficient of determination of the model of the speedup,
+// over the same threads that FitScaling uses.
+func r2(threads []int, speedup []float64, model func(n float64) float64,
+) float64 {
+	var mean, count float64
+	for _, v := range speedup {
+		if v <= 0 || math.IsNaN(v) {
+			continue
+		}
+		mean += v
+		count++
+	}
+	mean /= count
+	var res, tot float64
+	for i, t := range threads {
+		if speedup[i] <= 0 || math.IsNaN(speedup[i]) {
+			continue
+		}
+		res += math.Pow(speedup[i]-model(float64(t)), 2)
+		tot += math.Pow(speedup[i]-mean, 2)
+	}
+	if tot == 0 {
+		return 1
+	}
+	return 1 - res/tot
+}
+
+// Speedup returns the speedup at n threads that the USL predicts.
+func (s Scaling) Speedup(n float64) float64 {
+	return usl(n, s.Sigma, s.Kappa)
+}
+
+// AmdahlSpeedup returns the speedup at n threads that Amdahl's law predicts.
+func (s Scaling) AmdahlSpeedup(n float64) float64 {
+	return usl(n, s.Serial, 0)
+}
+
+// Peak returns the threads at which the USL predicts the most throughput,
+// which is sqrt((1-σ)/κ). It's infinite when there's no coherency cost, as
+// the throughput then keeps growing towards 1/σ times that of one thread.
+func (s Scaling) Peak() float64 {
+	switch {
+	case s.Sigma >= 1:
+		return 1
+	case s.Kappa <= 0:
+		return math.Inf(1)
+	}
+	return math.Sqrt((1 - s.Sigma) / s.Kappa)
+}
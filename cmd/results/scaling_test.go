@@ -0,0 +1,103 @@
+package results
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitScaling(t *testing.T) {
+	threads := []int{1, 2, 4, 6, 8, 12, 16, 24, 32}
+	curve := func(sigma, kappa float64) []float64 {
+		var speedup []float64
+		for _, n := range threads {
+			speedup = append(speedup, usl(float64(n), sigma, kappa))
+		}
+		return speedup
+	}
+	perfect := make([]float64, len(threads))
+	for i, n := range threads {
+		perfect[i] = float64(n)
+	}
+	// The fit of an exact curve gets back its coefficients, and Amdahl's
+	// law can only come close to a curve with a peak.
+	tests := []struct {
+		name         string
+		speedup      []float64
+		sigma, kappa float64
+		serial       float64
+		peak         float64
+	}{
+		{"usl", curve(0.05, 0.001), 0.05, 0.001, -1, 30.8221},
+		{"amdahl", curve(0.1, 0), 0.1, 0, 0.1, math.Inf(1)},
+		{"perfect", perfect, 0, 0, 0, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		s, ok := FitScaling(threads, tt.speedup)
+		if !ok {
+			t.Fatalf("%s: no fit", tt.name)
+		}
+		if !near(s.Sigma, tt.sigma) || !near(s.Kappa, tt.kappa) {
+			t.Errorf("%s: σ %v and κ %v, want %v and %v", tt.name,
+				s.Sigma, s.Kappa, tt.sigma, tt.kappa)
+		}
+		if tt.serial >= 0 && !near(s.Serial, tt.serial) {
+			t.Errorf("%s: serial %v, want %v", tt.name, s.Serial,
+				tt.serial)
+		}
+		if !near(s.R2, 1) {
+			t.Errorf("%s: R² %v, want 1", tt.name, s.R2)
+		}
+		if p := s.Peak(); p != tt.peak && !near(p, tt.peak) {
+			t.Errorf("%s: peak %v, want %v", tt.name, p, tt.peak)
+		}
+		for i, n := range threads {
+			if v := s.Speedup(float64(n)); !near(v, tt.speedup[i]) {
+				t.Errorf("%s: speedup at %d is %v, want %v",
+					tt.name, n, v, tt.speedup[i])
+			}
+		}
+	}
+
+	// The USL curve falls after its peak, which Amdahl's law can't.
+	s, _ := FitScaling(threads, curve(0.05, 0.001))
+	if s.AmdahlR2 >= s.R2 {
+		t.Errorf("Amdahl R² %v is not below the USL R² %v",
+			s.AmdahlR2, s.R2)
+	}
+	if v := s.AmdahlSpeedup(32); !(v > usl(32, 0.05, 0.001)) {
+		t.Errorf("Amdahl speedup at 32 is %v, want above the USL", v)
+	}
+
+	// A missing speedup is left out of the fit and of its R².
+	s, ok := FitScaling([]int{1, 2, 4, 8},
+		[]float64{1, math.NaN(), 3.5, 6})
+	if !ok || s.NumPoints != 3 {
+		t.Fatalf("fit %v of %d points, want 3", ok, s.NumPoints)
+	}
+	for _, r2 := range []float64{s.R2, s.AmdahlR2} {
+		if math.IsNaN(r2) || r2 <= 0 || r2 > 1 {
+			t.Errorf("R² %v and Amdahl R² %v, want within (0, 1]",
+				s.R2, s.AmdahlR2)
+		}
+	}
+}
+
+func TestFitScalingPoints(t *testing.T) {
+	tests := []struct {
+		threads []int
+		speedup []float64
+		ok      bool
+	}{
+		{[]int{1, 2}, []float64{1, 1.8}, true},
+		{[]int{1, 2}, []float64{1, 0}, false},
+		{[]int{1, 2, 4}, []float64{1, math.NaN(), 0}, false},
+		{[]int{1}, []float64{1}, false},
+		{nil, nil, false},
+	}
+	for _, tt := range tests {
+		if _, ok := FitScaling(tt.threads, tt.speedup); ok != tt.ok {
+			t.Errorf("FitScaling(%v, %v) is %v, want %v",
+				tt.threads, tt.speedup, ok, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package results
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// InfoFields are the names of the info fields, in encoding order.
+var InfoFields = []string{
+	"cache", "version", "threads", "bench_threads", "connections",
+	"operations", "sizerange", "pipeline", "host", "sweep", "kind",
+}
+
+// Field returns the value of the named info field as a string.
+func (info Info) Field(name string) (string, bool) {
+	switch name {
+	case "cache":
+		return info.Cache, true
+	case "version":
+		return info.Version, true
+	case "threads":
+		return strconv.Itoa(info.Threads), true
+	case "bench_threads":
+		return strconv.Itoa(info.BenchThreads), true
+	case "connections":
+		return strconv.Itoa(info.Connections), true
+	case "operations":
+		return strconv.Itoa(info.Operations), true
+	case "sizerange":
+		return info.Sizerange, true
+	case "pipeline":
+		return strconv.Itoa(info.Pipeline), true
+	case "host":
+		return info.Host, true
+	case "sweep":
+		return info.Sweep, true
+	case "kind":
+		return info.Kind, true
+	}
+	return "", false
+}
+
+// SetField sets the named info field from a string, which is the reverse of
+// Field.
+func (info *Info) SetField(name, value string) error {
+	num := func(p *int) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s'", name, value)
+		}
+		*p = n
+		return nil
+	}
+	switch name {
+	case "cache":
+		info.Cache = value
+	case "version":
+		info.Version = value
+	case "threads":
+		return num(&info.Threads)
+	case "bench_threads":
+		return num(&info.BenchThreads)
+	case "connections":
+		return num(&info.Connections)
+	case "operations":
+		return num(&info.Operations)
+	case "sizerange":
+		info.Sizerange = value
+	case "pipeline":
+		return num(&info.Pipeline)
+	case "host":
+		info.Host = value
+	case "sweep":
+		info.Sweep = value
+	case "kind":
+		info.Kind = value
+	default:
+		return fmt.Errorf("unknown field '%s'", name)
+	}
+	return nil
+}
+
+// Column is a single column in the flattened form of a record.
+type Column struct {
+	Name    string
+	Numeric bool
+}
+
+// Columns returns the columns of the flattened form of a record. That is the
+// file, its source, each info field, whether perf was used, and every metric.
+func Columns() []Column {
+	cols := []Column{{Name: "file"}, {Name: "source"}}
+	for _, name := range InfoFields {
+		switch name {
+		case "threads", "bench_threads", "connections", "operations",
+			"pipeline":
+			cols = append(cols, Column{Name: name, Numeric: true})
+		default:
+			cols = append(cols, Column{Name: name})
+		}
+	}
+	cols = append(cols, Column{Name: "perf"})
+	var r Run
+	for _, f := range r.Fields() {
+		cols = append(cols, Column{Name: f.Name, Numeric: true})
+	}
+	return cols
+}
+
+// Row returns the values of the flattened form of a record, in the same
+// order as Columns. The perf columns are empty for runs without perf, and so
+// are the values that are missing.
+func (rec Record) Row() []string {
+	row := []string{rec.File, rec.Source}
+	for _, name := range InfoFields {
+		v, _ := rec.Data.Info.Field(name)
+		row = append(row, v)
+	}
+	row = append(row, rec.Data.Key().Perf)
+	noperf := rec.Data.Perf.Empty()
+	for _, f := range rec.Data.Fields() {
+		if (noperf && strings.HasPrefix(f.Name, "perf.")) ||
+			math.IsNaN(*f.Ptr) {
+			row = append(row, "")
+		} else {
+			row = append(row, strconv.FormatFloat(*f.Ptr, 'f', f.Prec, 64))
+		}
+	}
+	return row
+}
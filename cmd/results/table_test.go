@@ -0,0 +1,47 @@
+package results
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInfoField(t *testing.T) {
+	info := testRun().Info
+	info.Sweep = "nightly"
+	var set Info
+	for _, name := range InfoFields {
+		v, ok := info.Field(name)
+		if !ok {
+			t.Fatalf("no field %s", name)
+		}
+		if err := set.SetField(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if set != info {
+		t.Fatalf("set fields to %+v, want %+v", set, info)
+	}
+	if v, _ := info.Field("bench_threads"); v != "16" {
+		t.Errorf("bench_threads is '%s', want '16'", v)
+	}
+	if _, ok := info.Field("color"); ok {
+		t.Errorf("has a field color")
+	}
+	tests := []struct {
+		name, value, err string
+	}{
+		{"threads", "four", "invalid threads 'four'"},
+		{"pipeline", "", "invalid pipeline ''"},
+		{"color", "red", "unknown field 'color'"},
+	}
+	for _, tt := range tests {
+		err := set.SetField(tt.name, tt.value)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("SetField(%s, %s) error %v, want %s", tt.name,
+				tt.value, err, tt.err)
+		}
+	}
+	if set != info {
+		t.Errorf("invalid values changed the fields to %+v", set)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/cache-benchmarks/results"
+)
+
+var dir string = "results"
+var kind string = "median"
+var which string = "get,set"
+var pipeline int
+var include string
+var exclude string
+var predict int = 64
+var format string = "text"
+
+// series is the throughput of a cache at each threads.
+type series struct {
+	name  string
+	opsec map[int]float64
+}
+
+func main() {
+	flag.StringVar(&dir, "dir", dir, "Results directory")
+	flag.StringVar(&kind, "kind", kind, "median,average,best,worst")
+	flag.StringVar(&which, "which", which, "Operations: get,set")
+	flag.IntVar(&pipeline, "pipeline", pipeline, "Only this pipeline "+
+		"(default is every pipeline)")
+	flag.StringVar(&include, "include", include, "Only analyze the runs "+
+		"that match, such as 'cache=valkey|redis'")
+	flag.StringVar(&exclude, "exclude", exclude, "Don't analyze the runs "+
+		"that match, such as 'cache=garnet'")
+	flag.IntVar(&predict, "predict", predict, "Threads to predict the "+
+		"speedup at, such as the cores of a larger machine")
+	flag.StringVar(&format, "format", format, "text,markdown")
+	flag.Parse()
+
+	switch kind {
+	case "median", "average", "best", "worst":
+	default:
+		fmt.Printf("invalid flag --kind='%s'\n", kind)
+		os.Exit(1)
+	}
+	switch format {
+	case "text", "markdown":
+	default:
+		fmt.Printf("invalid flag --format='%s'\n", format)
+		os.Exit(1)
+	}
+	if predict < 1 {
+		fmt.Printf("invalid flag --predict='%d'\n", predict)
+		os.Exit(1)
+	}
+	var ops []string
+	for _, op := range strings.Split(which, ",") {
+		switch op {
+		case "get", "set":
+			ops = append(ops, op)
+		default:
+			fmt.Printf("invalid flag --which='%s'\n", which)
+			os.Exit(1)
+		}
+	}
+	var incl, excl results.Filter
+	var err error
+	if include != "" {
+		if incl, err = results.ParseFilter(include); err != nil {
+			fmt.Printf("invalid flag --include='%s'\n", include)
+			os.Exit(1)
+		}
+	}
+	if exclude != "" {
+		if excl, err = results.ParseFilter(exclude); err != nil {
+			fmt.Printf("invalid flag --exclude='%s'\n", exclude)
+			os.Exit(1)
+		}
+	}
+
+	all, err := results.ReadOutput(dir + "/output.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	// The runs with perf counters are left out, as 'perf stat' slows the
+	// cache down.
+	var recs []results.Record
+	var origins bool
+	for _, rec := range all {
+		info := rec.Data.Info
+		if info.Kind != kind || !rec.Data.Perf.Empty() ||
+			(pipeline != 0 && info.Pipeline != pipeline) ||
+			!incl.Match(info) || (excl != nil && excl.Match(info)) {
+			continue
+		}
+		recs = append(recs, rec)
+		origins = origins || rec.Origin() != recs[0].Origin()
+	}
+	if len(recs) == 0 {
+		fmt.Fprintf(os.Stderr, "%s/output.json: no records match the "+
+			"filters\n", dir)
+		os.Exit(1)
+	}
+	var pipelines []int
+	seen := map[int]bool{}
+	for _, rec := range recs {
+		if p := rec.Data.Info.Pipeline; !seen[p] {
+			seen[p] = true
+			pipelines = append(pipelines, p)
+		}
+	}
+	sort.Ints(pipelines)
+
+	for _, p := range pipelines {
+		var sel []results.Record
+		for _, rec := range recs {
+			if rec.Data.Info.Pipeline == p {
+				sel = append(sel, rec)
+			}
+		}
+		for _, op := range ops {
+			analyze(strings.ToUpper(op), p, op+"s.opsec", sel,
+				origins)
+		}
+	}
+}
+
+// analyze prints the speedup and parallel efficiency of each cache at each
+// threads, and the fits of Amdahl's law and the USL, for the throughput of
+// the operation at the pipeline.
+func analyze(label string, pipeline int, metric string,
+	recs []results.Record, origins bool,
+) {
+	var all []series
+	idx := map[string]int{}
+	tm := map[int]bool{}
+	var threadz []int
+	for _, rec := range recs {
+		name := rec.Data.Info.Cache
+		if origins {
+			// Each host or sweep has its own series, like in the
+			// graphs.
+			name += " (" + rec.Origin() + ")"
+		}
+		i, ok := idx[name]
+		if !ok {
+			i = len(all)
+			idx[name] = i
+			all = append(all, series{name, map[int]float64{}})
+		}
+		t := rec.Data.Info.Threads
+		all[i].opsec[t], _ = rec.Data.Value(metric)
+		if !tm[t] {
+			tm[t] = true
+			threadz = append(threadz, t)
+		}
+	}
+	sort.Ints(threadz)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].name < all[j].name
+	})
+
+	writeHeading(fmt.Sprintf("%s - Pipeline %d - %s", label, pipeline,
+		kind))
+	header := []string{"Speedup"}
+	for _, t := range threadz {
+		header = append(header, strconv.Itoa(t))
+	}
+	var speedups, efficiencies, summary, missing [][]string
+	for _, s := range all {
+		base, ok := s.opsec[1]
+		if !ok || base <= 0 {
+			missing = append(missing, []string{s.name})
+			continue
+		}
+		var ts []int
+		var sp []float64
+		srow := []string{s.name}
+		erow := []string{s.name}
+		for _, t := range threadz {
+			v, ok := s.opsec[t]
+			if !ok {
+				srow = append(srow, "-")
+				erow = append(erow, "-")
+				continue
+			}
+			ts = append(ts, t)
+			sp = append(sp, v/base)
+			srow = append(srow, fmt.Sprintf("%.2f", v/base))
+			erow = append(erow, fmt.Sprintf("%.0f%%",
+				v/base/float64(t)*100))
+		}
+		speedups = append(speedups, srow)
+		efficiencies = append(efficiencies, erow)
+		summary = append(summary, summarize(s, base, ts, sp))
+	}
+	if len(speedups) > 0 {
+		writeTable(header, speedups)
+		header[0] = "Efficiency"
+		writeTable(header, efficiencies)
+		writeTable([]string{"Cache", "1 Thread (Kops/sec)",
+			"Best Threads", "Best Speedup", "Amdahl σ", "USL σ", "USL κ",
+			"USL R²", "Peak Threads",
+			fmt.Sprintf("Speedup at %d", predict),
+			fmt.Sprintf("Kops/sec at %d", predict)}, summary)
+	}
+	if len(missing) > 0 {
+		writeTable([]string{"Without a run at 1 thread"}, missing)
+	}
+}
+
+// summarize returns the summary row of the series, with the most speedup
+// that was measured, and the fits of Amdahl's law and the USL.
+func summarize(s series, base float64, threadz []int, speedup []float64,
+) []string {
+	best := 0
+	for i := range speedup {
+		if speedup[i] > speedup[best] {
+			best = i
+		}
+	}
+	row := []string{s.name, fmt.Sprintf("%.0f", base/1000),
+		strconv.Itoa(threadz[best]), fmt.Sprintf("%.2f", speedup[best])}
+	fit, ok := results.FitScaling(threadz, speedup)
+	if !ok {
+		return append(row, "-", "-", "-", "-", "-", "-", "-")
+	}
+	peak := "none"
+	if p := fit.Peak(); !math.IsInf(p, 1) {
+		peak = fmt.Sprintf("%.0f", p)
+	}
+	at := fit.Speedup(float64(predict))
+	return append(row, coef(fit.Serial), coef(fit.Sigma), coef(fit.Kappa),
+		fmt.Sprintf("%.3f", fit.R2), peak, fmt.Sprintf("%.2f", at),
+		fmt.Sprintf("%.0f", base*at/1000))
+}
+
+// coef returns a coefficient of a fit with three significant digits.
+func coef(v float64) string {
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+// writeHeading writes the title of a section.
+func writeHeading(title string) {
+	if format == "markdown" {
+		fmt.Printf("### %s\n\n", title)
+		return
+	}
+	fmt.Printf("%s\n\n", title)
+}
+
+// writeTable writes the rows with a header, as text in aligned columns or
+// as markdown.
+func writeTable(header []string, rows [][]string) {
+	if format == "markdown" {
+		fmt.Printf("| %s |\n", strings.Join(header, " | "))
+		fmt.Printf("| --- |%s\n",
+			strings.Repeat(" ---: |", len(header)-1))
+		for _, row := range rows {
+			fmt.Printf("| %s |\n", strings.Join(row, " | "))
+		}
+		fmt.Printf("\n")
+		return
+	}
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		var line string
+		for i, cell := range row {
+			pad := strings.Repeat(" ", widths[i]-len([]rune(cell)))
+			if i == 0 {
+				line += cell + pad
+			} else {
+				line += "  " + pad + cell
+			}
+		}
+		fmt.Printf("%s\n", strings.TrimRight(line, " "))
+	}
+	fmt.Printf("\n")
+}